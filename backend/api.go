@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nithish-95/manga/backend/catalog"
+	"github.com/nithish-95/manga/backend/mangadex"
+	"github.com/nithish-95/manga/backend/openapi"
+)
+
+const (
+	// apiDefaultLimit and apiMaxLimit bound the page size of list endpoints.
+	apiDefaultLimit = 20
+	apiMaxLimit     = 100
+)
+
+// apiRoute is one endpoint of the JSON API. The router and the OpenAPI
+// document are both built from apiRoutes so they cannot disagree.
+type apiRoute struct {
+	path     string
+	handler  http.HandlerFunc
+	summary  string
+	params   []openapi.Parameter
+	response reflect.Type
+	errors   []string // documented error statuses
+}
+
+var apiRoutes = []apiRoute{
+	{
+		path: "/search", handler: apiSearchHandler, summary: "Search manga by title",
+		params:   append([]openapi.Parameter{queryParam("q", "Title to search for", true)}, pageQueryParams...),
+		response: reflect.TypeFor[ListResponse[mangadex.Manga]](),
+		errors:   []string{"400", "502"},
+	},
+	{
+		path: "/popular", handler: apiPopularHandler, summary: "List the most followed manga",
+		params:   pageQueryParams,
+		response: reflect.TypeFor[ListResponse[mangadex.Manga]](),
+		errors:   []string{"400", "502"},
+	},
+	{
+		path: "/recent", handler: apiRecentHandler, summary: "List recently updated manga",
+		params:   pageQueryParams,
+		response: reflect.TypeFor[ListResponse[mangadex.Manga]](),
+		errors:   []string{"400", "502"},
+	},
+	{
+		path: "/random", handler: apiRandomHandler, summary: "Fetch distinct random manga",
+		params: []openapi.Parameter{
+			{Name: "limit", In: "query", Description: "Number of manga to return", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "tag", In: "query", Description: "Tag ID or English name every result must carry; repeatable", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "contentRating", In: "query", Description: "Content rating to narrow results to; repeatable", Schema: &openapi.Schema{Type: "string", Enum: mangadex.ContentRatings}},
+		},
+		response: reflect.TypeFor[RandomResponse](),
+		errors:   []string{"400", "502"},
+	},
+	{
+		path: "/tags", handler: apiTagsHandler, summary: "List every tag",
+		response: reflect.TypeFor[ItemResponse[[]mangadex.Tag]](),
+		errors:   []string{"502"},
+	},
+	{
+		path: "/manga/{mangaID}", handler: apiMangaHandler, summary: "Get a manga with its cover and statistics",
+		params:   []openapi.Parameter{pathParam("mangaID")},
+		response: reflect.TypeFor[ItemResponse[mangadex.Manga]](),
+		errors:   []string{"403", "404", "502"},
+	},
+	{
+		path: "/manga/{mangaID}/chapters", handler: apiChaptersHandler, summary: "List the chapters of a manga",
+		params:   append([]openapi.Parameter{pathParam("mangaID")}, pageQueryParams...),
+		response: reflect.TypeFor[ListResponse[mangadex.ChapterData]](),
+		errors:   []string{"400", "403", "404", "502"},
+	},
+	{
+		path: "/chapters/{chapterID}", handler: apiChapterHandler, summary: "Get a chapter's metadata",
+		params:   []openapi.Parameter{pathParam("chapterID")},
+		response: reflect.TypeFor[ItemResponse[mangadex.Chapter]](),
+		errors:   []string{"403", "404", "502"},
+	},
+	{
+		path: "/chapters/{chapterID}/pages", handler: apiChapterPagesHandler, summary: "Get a chapter's page image URLs",
+		params:   []openapi.Parameter{pathParam("chapterID")},
+		response: reflect.TypeFor[ItemResponse[ChapterPages]](),
+		errors:   []string{"403", "404", "502"},
+	},
+}
+
+var pageQueryParams = []openapi.Parameter{
+	{Name: "page", In: "query", Description: "Page number, starting at 1", Schema: &openapi.Schema{Type: "integer"}},
+	{Name: "limit", In: "query", Description: fmt.Sprintf("Page size, at most %d", apiMaxLimit), Schema: &openapi.Schema{Type: "integer"}},
+}
+
+func pathParam(name string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+}
+
+func queryParam(name, description string, required bool) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Required: required, Schema: &openapi.Schema{Type: "string"}}
+}
+
+// apiDocument is the OpenAPI description of the JSON API.
+var apiDocument = buildAPIDocument()
+
+func buildAPIDocument() *openapi.Document {
+	doc := openapi.New("MangaFlow API", "1")
+	doc.Servers = []openapi.Server{{URL: "/api/v1"}}
+	for _, route := range apiRoutes {
+		operationID := strings.ReplaceAll(strings.Trim(route.path, "/"), "/", "_")
+		operationID = strings.NewReplacer("{", "", "}", "").Replace(operationID)
+		doc.AddGet(route.path, &openapi.Operation{
+			OperationID: operationID,
+			Summary:     route.summary,
+			Parameters:  route.params,
+		}, route.response, reflect.TypeFor[APIError](), route.errors...)
+	}
+	return doc
+}
+
+// apiRouter returns the versioned JSON API. Every endpoint goes through
+// catalogService with the same content filter as the HTML pages.
+func apiRouter() chi.Router {
+	r := chi.NewRouter()
+	for _, route := range apiRoutes {
+		r.Get(route.path, route.handler)
+	}
+	r.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, apiDocument)
+	})
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "no such endpoint")
+	})
+	return r
+}
+
+// ListResponse is the envelope of every list endpoint.
+type ListResponse[T any] struct {
+	Data       []T                `json:"data"`
+	Pagination catalog.Pagination `json:"pagination"`
+}
+
+// ItemResponse is the envelope of every single-item endpoint.
+type ItemResponse[T any] struct {
+	Data T `json:"data"`
+}
+
+// APIError is the body of every error response.
+type APIError struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// RandomResponse is the body of the random endpoint. Partial is set when
+// fewer mangas than requested could be fetched.
+type RandomResponse struct {
+	Data      []mangadex.Manga `json:"data"`
+	Requested int              `json:"requested"`
+	Partial   bool             `json:"partial"`
+}
+
+// ChapterPages is the body of the chapter pages endpoint. Pages is empty
+// for external and unavailable chapters.
+type ChapterPages struct {
+	Chapter mangadex.Chapter `json:"chapter"`
+	Pages   []string         `json:"pages"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Error encoding JSON response", "err", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	var body APIError
+	body.Error.Status = status
+	body.Error.Message = message
+	writeJSON(w, status, body)
+}
+
+// pageParams reads the page and limit query parameters.
+func pageParams(r *http.Request) (page, limit int, err error) {
+	page, limit = 1, apiDefaultLimit
+	if v := r.URL.Query().Get("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("page must be a positive integer")
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > apiMaxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", apiMaxLimit)
+		}
+	}
+	return page, limit, nil
+}
+
+// writeMangaList serves a paginated manga listing fetched by fetch.
+func writeMangaList(w http.ResponseWriter, r *http.Request, fetch func(page, limit int, filter mangadex.Filter) (*catalog.MangaList, error)) {
+	page, limit, err := pageParams(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list, err := fetch(page, limit, requestFilter(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching manga list", "path", r.URL.Path, "err", err)
+		writeAPIError(w, http.StatusBadGateway, "failed to fetch manga list")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListResponse[mangadex.Manga]{
+		Data:       nonNil(list.Mangas),
+		Pagination: list.Pagination,
+	})
+}
+
+// writeCatalogError writes the API error response for a failed catalog
+// call on a manga or chapter.
+func writeCatalogError(w http.ResponseWriter, r *http.Request, err error, what string) {
+	var restricted *catalog.RestrictedError
+	switch {
+	case errors.As(err, &restricted):
+		if contentAccess(r, restricted.Manga) == http.StatusNotFound {
+			writeAPIError(w, http.StatusNotFound, what+" not found")
+			return
+		}
+		writeAPIError(w, http.StatusForbidden, fmt.Sprintf("manga is rated %s; opt in at /settings/content to view it", restricted.Manga.Attributes.ContentRating))
+	case errors.Is(err, mangadex.ErrNotFound):
+		writeAPIError(w, http.StatusNotFound, what+" not found")
+	default:
+		slog.ErrorContext(r.Context(), "Error fetching "+what, "path", r.URL.Path, "err", err)
+		writeAPIError(w, http.StatusBadGateway, "failed to fetch "+what)
+	}
+}
+
+// apiSearchHandler searches manga by title given in the q parameter.
+func apiSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeAPIError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	writeMangaList(w, r, func(page, limit int, filter mangadex.Filter) (*catalog.MangaList, error) {
+		return catalogService.Search(r.Context(), query, page, limit, filter)
+	})
+}
+
+// apiPopularHandler lists the most followed manga.
+func apiPopularHandler(w http.ResponseWriter, r *http.Request) {
+	writeMangaList(w, r, func(page, limit int, filter mangadex.Filter) (*catalog.MangaList, error) {
+		return catalogService.List(r.Context(), catalog.Popular, page, limit, filter)
+	})
+}
+
+// apiRecentHandler lists the most recently updated manga.
+func apiRecentHandler(w http.ResponseWriter, r *http.Request) {
+	writeMangaList(w, r, func(page, limit int, filter mangadex.Filter) (*catalog.MangaList, error) {
+		return catalogService.List(r.Context(), catalog.Recent, page, limit, filter)
+	})
+}
+
+// apiRandomHandler returns random mangas. It accepts a limit of up to
+// mangadex.MaxRandomMangas, repeated tag parameters (IDs or English names)
+// that every result must carry, and repeated contentRating parameters that
+// narrow the visitor's allowed ratings.
+func apiRandomHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	count := 1 // Default to 1 if not specified
+	if countStr := query.Get("limit"); countStr != "" {
+		var err error
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count < 1 || count > mangadex.MaxRandomMangas {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", mangadex.MaxRandomMangas))
+			return
+		}
+	}
+
+	filter := requestFilter(r)
+	if tags := query["tag"]; len(tags) > 0 {
+		tagIDs, err := mangadex.ResolveTagIDs(r.Context(), tags)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter.IncludedTags = tagIDs
+	}
+	if ratings := query["contentRating"]; len(ratings) > 0 {
+		var narrowed []string
+		for _, rating := range filter.ContentRatings {
+			if slices.Contains(ratings, rating) {
+				narrowed = append(narrowed, rating)
+			}
+		}
+		if len(narrowed) == 0 {
+			writeAPIError(w, http.StatusBadRequest, "none of the requested content ratings are allowed")
+			return
+		}
+		filter.ContentRatings = narrowed
+	}
+
+	picks, err := catalogService.Random(r.Context(), count, filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching random mangas", "err", err)
+		writeAPIError(w, http.StatusBadGateway, "failed to fetch random mangas")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RandomResponse{
+		Data:      nonNil(picks.Mangas),
+		Requested: picks.Requested,
+		Partial:   picks.Partial,
+	})
+}
+
+// apiTagsHandler lists every tag.
+func apiTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags, err := catalogService.Tags(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching tags", "err", err)
+		writeAPIError(w, http.StatusBadGateway, "failed to fetch tags")
+		return
+	}
+	writeJSON(w, http.StatusOK, ItemResponse[[]mangadex.Tag]{Data: nonNil(tags)})
+}
+
+// apiMangaHandler returns a manga with its cover and statistics.
+func apiMangaHandler(w http.ResponseWriter, r *http.Request) {
+	manga, err := catalogService.Manga(r.Context(), chi.URLParam(r, "mangaID"), requestFilter(r))
+	if err != nil {
+		writeCatalogError(w, r, err, "manga")
+		return
+	}
+	writeJSON(w, http.StatusOK, ItemResponse[mangadex.Manga]{Data: manga})
+}
+
+// apiChaptersHandler lists the chapters of a manga.
+func apiChaptersHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := pageParams(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chapters, err := catalogService.Chapters(r.Context(), chi.URLParam(r, "mangaID"), page, limit, requestFilter(r))
+	if err != nil {
+		writeCatalogError(w, r, err, "chapters")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListResponse[mangadex.ChapterData]{
+		Data:       nonNil(chapters.Chapters),
+		Pagination: chapters.Pagination,
+	})
+}
+
+// apiChapterHandler returns a chapter's metadata.
+func apiChapterHandler(w http.ResponseWriter, r *http.Request) {
+	chapter, err := catalogService.Chapter(r.Context(), chi.URLParam(r, "chapterID"), requestFilter(r))
+	if err != nil {
+		writeCatalogError(w, r, err, "chapter")
+		return
+	}
+	writeJSON(w, http.StatusOK, ItemResponse[mangadex.Chapter]{Data: chapter})
+}
+
+// apiChapterPagesHandler returns a chapter's page image URLs. External and
+// unavailable chapters have no pages; clients should check the chapter's
+// externalUrl and isUnavailable attributes.
+func apiChapterPagesHandler(w http.ResponseWriter, r *http.Request) {
+	chapter, pages, err := catalogService.ChapterPages(r.Context(), chi.URLParam(r, "chapterID"), requestFilter(r))
+	if err != nil {
+		writeCatalogError(w, r, err, "chapter")
+		return
+	}
+	writeJSON(w, http.StatusOK, ItemResponse[ChapterPages]{Data: ChapterPages{Chapter: chapter, Pages: nonNil(pages)}})
+}
+
+// nonNil turns a nil slice into an empty one so it encodes as [] not null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
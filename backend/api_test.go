@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+// fakeMangaDex serves canned MangaDex API responses and points the mangadex
+// package at it. Manga "restricted" is rated erotica, "missing" does not
+// exist, "flaky" is unavailable the first time it is asked for, and while
+// down is set every request fails.
+func fakeMangaDex(t *testing.T) (down *atomic.Bool) {
+	t.Helper()
+	down = new(atomic.Bool)
+	var randomCalls atomic.Int64
+	var flakyFailed atomic.Bool
+
+	manga := func(id string) map[string]any {
+		rating := mangadex.RatingSafe
+		if id == "restricted" {
+			rating = mangadex.RatingErotica
+		}
+		return map[string]any{
+			"id": id, "type": "manga",
+			"attributes": map[string]any{
+				"title":            map[string]string{"en": "Manga " + id},
+				"description":      map[string]string{"en": "About " + id},
+				"contentRating":    rating,
+				"originalLanguage": "ja",
+				"status":           "ongoing",
+				"year":             2020,
+				"tags": []any{
+					map[string]any{"id": "tag1", "type": "tag", "attributes": map[string]any{"name": map[string]string{"en": "Action"}, "group": "genre"}},
+				},
+			},
+			"relationships": []any{
+				map[string]any{"id": "author1", "type": "author", "attributes": map[string]string{"name": "Author One"}},
+			},
+		}
+	}
+	chapter := func(mangaID string, n int) map[string]any {
+		return map[string]any{
+			"id": fmt.Sprintf("%s-ch%d", mangaID, n), "type": "chapter",
+			"attributes": map[string]any{
+				"chapter":            fmt.Sprint(n),
+				"volume":             "1",
+				"title":              fmt.Sprintf("Title %d", n),
+				"translatedLanguage": "en",
+				"pages":              2,
+				"publishAt":          "2025-01-01T00:00:00+00:00",
+				"readableAt":         "2025-01-01T00:00:00+00:00",
+			},
+			"relationships": []any{
+				map[string]any{"id": mangaID, "type": "manga"},
+				map[string]any{"id": "group1", "type": "scanlation_group", "attributes": map[string]string{"name": "Group One"}},
+			},
+		}
+	}
+
+	mux := http.NewServeMux()
+	send := func(w http.ResponseWriter, body any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}
+	mux.HandleFunc("GET /manga", func(w http.ResponseWriter, r *http.Request) {
+		send(w, map[string]any{"result": "ok", "data": []any{manga("m1"), manga("m2")}, "total": 42})
+	})
+	mux.HandleFunc("GET /manga/random", func(w http.ResponseWriter, r *http.Request) {
+		send(w, map[string]any{"result": "ok", "data": manga(fmt.Sprintf("r%d", randomCalls.Add(1)))})
+	})
+	mux.HandleFunc("GET /manga/tag", func(w http.ResponseWriter, r *http.Request) {
+		send(w, map[string]any{"result": "ok", "data": []any{
+			map[string]any{"id": "tag1", "type": "tag", "attributes": map[string]any{"name": map[string]string{"en": "Action"}, "group": "genre"}},
+		}})
+	})
+	mux.HandleFunc("GET /manga/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.PathValue("id") == "missing":
+			http.Error(w, `{"result":"error"}`, http.StatusNotFound)
+			return
+		case r.PathValue("id") == "flaky" && flakyFailed.CompareAndSwap(false, true):
+			http.Error(w, `{"result":"error"}`, http.StatusServiceUnavailable)
+			return
+		}
+		send(w, map[string]any{"result": "ok", "data": manga(r.PathValue("id"))})
+	})
+	mux.HandleFunc("GET /manga/{id}/feed", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if id == "missing" {
+			http.Error(w, `{"result":"error"}`, http.StatusNotFound)
+			return
+		}
+		send(w, map[string]any{"result": "ok", "data": []any{chapter(id, 1), chapter(id, 2)}, "total": 2})
+	})
+	mux.HandleFunc("GET /chapter/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mangaID, n, ok := strings.Cut(r.PathValue("id"), "-ch")
+		if !ok || mangaID == "missing" {
+			http.Error(w, `{"result":"error"}`, http.StatusNotFound)
+			return
+		}
+		var number int
+		fmt.Sscan(n, &number)
+		send(w, map[string]any{"result": "ok", "data": chapter(mangaID, number)})
+	})
+	mux.HandleFunc("GET /at-home/server/{id}", func(w http.ResponseWriter, r *http.Request) {
+		send(w, map[string]any{"baseUrl": "http://" + r.Host, "chapter": map[string]any{"hash": "h", "data": []string{"1.png", "2.png"}, "dataSaver": []string{}}})
+	})
+	mux.HandleFunc("GET /cover", func(w http.ResponseWriter, r *http.Request) {
+		send(w, map[string]any{"result": "ok", "data": []any{map[string]any{"id": "cover1", "attributes": map[string]string{"fileName": "cover.jpg"}}}})
+	})
+	mux.HandleFunc("GET /statistics/manga", func(w http.ResponseWriter, r *http.Request) {
+		stats := make(map[string]any)
+		for _, id := range r.URL.Query()["manga[]"] {
+			stats[id] = map[string]any{"follows": 10, "rating": map[string]any{"average": 8, "bayesian": 7.5}}
+		}
+		send(w, map[string]any{"result": "ok", "statistics": stats})
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, `{"result":"error"}`, http.StatusInternalServerError)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	mangadex.Configure(mangadex.Options{APIBase: srv.URL, CoverBase: srv.URL + "/covers"})
+	return down
+}
+
+// TestAPIMatchesDocument calls every endpoint of the JSON API for each of
+// its documented statuses and checks the response against the OpenAPI
+// document.
+func TestAPIMatchesDocument(t *testing.T) {
+	down := fakeMangaDex(t)
+	router := apiRouter()
+
+	tests := []struct {
+		route  string
+		url    string
+		status int
+		down   bool // MangaDex fails
+	}{
+		// Failures come first so that nothing they fetch is cached yet.
+		{route: "/search", url: "/search?q=down", status: http.StatusBadGateway, down: true},
+		{route: "/popular", url: "/popular?page=9", status: http.StatusBadGateway, down: true},
+		{route: "/recent", url: "/recent?page=9", status: http.StatusBadGateway, down: true},
+		{route: "/random", url: "/random", status: http.StatusBadGateway, down: true},
+		{route: "/tags", url: "/tags", status: http.StatusBadGateway, down: true},
+		{route: "/manga/{mangaID}", url: "/manga/down", status: http.StatusBadGateway, down: true},
+		{route: "/manga/{mangaID}/chapters", url: "/manga/down/chapters", status: http.StatusBadGateway, down: true},
+		{route: "/chapters/{chapterID}", url: "/chapters/down-ch1", status: http.StatusBadGateway, down: true},
+		{route: "/chapters/{chapterID}/pages", url: "/chapters/down-ch2/pages", status: http.StatusBadGateway, down: true},
+
+		{route: "/search", url: "/search?q=one&page=2&limit=2", status: http.StatusOK},
+		{route: "/search", url: "/search", status: http.StatusBadRequest},
+		{route: "/popular", url: "/popular", status: http.StatusOK},
+		{route: "/popular", url: "/popular?limit=1000", status: http.StatusBadRequest},
+		{route: "/recent", url: "/recent?page=3", status: http.StatusOK},
+		{route: "/recent", url: "/recent?page=0", status: http.StatusBadRequest},
+		{route: "/random", url: "/random?limit=3&tag=Action&contentRating=safe", status: http.StatusOK},
+		{route: "/random", url: "/random?limit=0", status: http.StatusBadRequest},
+		{route: "/random", url: "/random?tag=No+Such+Tag", status: http.StatusBadRequest},
+		{route: "/tags", url: "/tags", status: http.StatusOK},
+		{route: "/manga/{mangaID}", url: "/manga/m1", status: http.StatusOK},
+		{route: "/manga/{mangaID}", url: "/manga/restricted", status: http.StatusForbidden},
+		{route: "/manga/{mangaID}", url: "/manga/missing", status: http.StatusNotFound},
+		{route: "/manga/{mangaID}/chapters", url: "/manga/m1/chapters?limit=1", status: http.StatusOK},
+		{route: "/manga/{mangaID}/chapters", url: "/manga/m1/chapters?page=x", status: http.StatusBadRequest},
+		{route: "/manga/{mangaID}/chapters", url: "/manga/restricted/chapters", status: http.StatusForbidden},
+		{route: "/manga/{mangaID}/chapters", url: "/manga/missing/chapters", status: http.StatusNotFound},
+		{route: "/chapters/{chapterID}", url: "/chapters/m1-ch1", status: http.StatusOK},
+		{route: "/chapters/{chapterID}", url: "/chapters/restricted-ch1", status: http.StatusForbidden},
+		{route: "/chapters/{chapterID}", url: "/chapters/missing-ch1", status: http.StatusNotFound},
+		{route: "/chapters/{chapterID}/pages", url: "/chapters/m1-ch2/pages", status: http.StatusOK},
+		{route: "/chapters/{chapterID}/pages", url: "/chapters/restricted-ch2/pages", status: http.StatusForbidden},
+		{route: "/chapters/{chapterID}/pages", url: "/chapters/missing-ch2/pages", status: http.StatusNotFound},
+	}
+
+	tested := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			down.Store(tt.down)
+			defer down.Store(false)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.status, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if err := apiDocument.ValidateResponse(tt.route, rec.Code, rec.Body.Bytes()); err != nil {
+				t.Errorf("response does not match the document: %v\nbody: %s", err, rec.Body)
+			}
+		})
+		tested[fmt.Sprint(tt.route, " ", tt.status)] = true
+	}
+
+	// Every route and every status it documents must be covered above.
+	for _, route := range apiRoutes {
+		for _, status := range append([]string{"200"}, route.errors...) {
+			if !tested[route.path+" "+status] {
+				t.Errorf("%s: status %s is not tested", route.path, status)
+			}
+		}
+	}
+}
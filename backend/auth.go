@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/nithish-95/manga/backend/config"
+	"github.com/nithish-95/manga/backend/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// sessionCookie holds the token of a logged-in visitor's session.
+	sessionCookie = "session"
+	// csrfCookie holds the token every state-changing form must echo back
+	// in its csrfField.
+	csrfCookie = "csrf"
+	csrfField  = "csrf_token"
+
+	minPasswordLength = 8
+	// maxPasswordLength is bcrypt's input limit.
+	maxPasswordLength = 72
+
+	// sessionSweepInterval is how often expired sessions are deleted.
+	sessionSweepInterval = time.Hour
+)
+
+// validUsername restricts usernames to a short, URL-safe alphabet.
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// db stores user accounts and everything kept per user.
+var db *store.Store
+
+// accounts is the account configuration, set at startup.
+var accounts config.Accounts
+
+// dummyPasswordHash is compared against when a login names an unknown user,
+// so that failed logins take the same time whether or not the user exists.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	csrfKey
+)
+
+// openDatabase opens the account database, closes it on shutdown and starts
+// sweeping expired sessions.
+func openDatabase(cfg *config.Config) error {
+	var err error
+	db, err = store.Open(cfg.Accounts.Database)
+	if err != nil {
+		return err
+	}
+	accounts = cfg.Accounts
+	onShutdown(func(context.Context) error { return db.Close() })
+
+	goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(sessionSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := db.DeleteExpiredSessions(); err != nil {
+					slog.Warn("Error deleting expired sessions", "err", err)
+				} else if n > 0 {
+					slog.Debug("Deleted expired sessions", "count", n)
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// currentUser returns the logged-in user of a request, or nil.
+func currentUser(r *http.Request) *store.User {
+	user, _ := r.Context().Value(userKey).(*store.User)
+	return user
+}
+
+// loadUser puts the user owning the request's session cookie, if any, in
+// the request context.
+func loadUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		session, err := db.Session(cookie.Value)
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				slog.ErrorContext(r.Context(), "Error reading session", "err", err)
+			}
+			clearSessionCookie(w, r)
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := db.User(session.UserID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error reading session user", "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
+	})
+}
+
+// requireUser sends visitors who are not logged in to the login page, which
+// returns them here afterwards.
+func requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r) == nil {
+			target := r.URL.RequestURI()
+			if r.Method != http.MethodGet {
+				target = localRedirect(r.Referer())
+			}
+			http.Redirect(w, r, "/login?return="+url.QueryEscape(target), http.StatusSeeOther)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireBasicAuth lets through visitors with a session or with HTTP Basic
+// credentials, which is how OPDS readers log in, and asks others for
+// credentials.
+func requireBasicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if username, password, ok := r.BasicAuth(); ok {
+			user, err := authenticate(username, password)
+			if err == nil {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
+				return
+			}
+			if !errors.Is(err, errBadCredentials) {
+				slog.ErrorContext(r.Context(), "Error reading user", "err", err)
+				http.Error(w, "Error logging in", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+siteName+`", charset="UTF-8"`)
+		http.Error(w, "Log in to see your library", http.StatusUnauthorized)
+	})
+}
+
+// csrfProtect issues every visitor a CSRF cookie and rejects state-changing
+// requests whose form field or X-CSRF-Token header does not match it.
+func csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+			token = cookie.Value
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			sent := r.Header.Get("X-CSRF-Token")
+			if sent == "" {
+				sent = r.PostFormValue(csrfField)
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				http.Error(w, "Invalid or missing CSRF token; reload the page and try again", http.StatusForbidden)
+				return
+			}
+		}
+
+		if token == "" {
+			b := make([]byte, 32)
+			rand.Read(b)
+			token = base64.RawURLEncoding.EncodeToString(b)
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   secureCookies(r),
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfKey, token)))
+	})
+}
+
+// csrfInput returns the hidden form field carrying the request's CSRF token.
+func csrfInput(r *http.Request) template.HTML {
+	token, _ := r.Context().Value(csrfKey).(string)
+	return template.HTML(`<input type="hidden" name="` + csrfField + `" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+// secureCookies reports whether cookies should be limited to HTTPS.
+func secureCookies(r *http.Request) bool {
+	return accounts.SecureCookies || r.TLS != nil
+}
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(accounts.SessionTTL),
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+}
+
+// startSession logs a user in and returns them to the page they came from.
+func startSession(w http.ResponseWriter, r *http.Request, user *store.User) {
+	token, err := db.CreateSession(user.ID, accounts.SessionTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating session", "err", err)
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return
+	}
+	setSessionCookie(w, r, token)
+	http.Redirect(w, r, localRedirect(r.PostForm.Get("return")), http.StatusSeeOther)
+}
+
+// loginHandler shows the login form, or returns visitors who are already
+// logged in to where they came from.
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) != nil {
+		http.Redirect(w, r, localRedirect(r.FormValue("return")), http.StatusSeeOther)
+		return
+	}
+	renderAuthForm(w, r, "login", "", "")
+}
+
+// doLoginHandler checks a username and password and starts a session.
+func doLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	username, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+
+	user, err := authenticate(username, password)
+	if errors.Is(err, errBadCredentials) {
+		w.WriteHeader(http.StatusUnauthorized)
+		renderAuthForm(w, r, "login", username, "Invalid username or password.")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading user", "err", err)
+		http.Error(w, "Error logging in", http.StatusInternalServerError)
+		return
+	}
+	startSession(w, r, user)
+}
+
+// errBadCredentials is returned by authenticate for an unknown username or
+// a wrong password, which are deliberately not told apart.
+var errBadCredentials = errors.New("invalid username or password")
+
+// authenticate returns the user with the given username and password. An
+// unknown username costs as much time as a wrong password.
+func authenticate(username, password string) (*store.User, error) {
+	user, err := db.UserByName(username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	hash := dummyPasswordHash
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil {
+		return nil, errBadCredentials
+	}
+	return user, nil
+}
+
+// registerHandler shows the registration form.
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	if !accounts.Registration {
+		http.Error(w, "Registration is closed on this server", http.StatusForbidden)
+		return
+	}
+	renderAuthForm(w, r, "register", "", "")
+}
+
+// doRegisterHandler creates an account and logs it in.
+func doRegisterHandler(w http.ResponseWriter, r *http.Request) {
+	if !accounts.Registration {
+		http.Error(w, "Registration is closed on this server", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	username, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+
+	var formError string
+	switch {
+	case !validUsername.MatchString(username):
+		formError = "Usernames are 3 to 32 letters, digits, dots, dashes or underscores."
+	case len(password) < minPasswordLength:
+		formError = "Passwords must be at least 8 characters long."
+	case len(password) > maxPasswordLength:
+		formError = "Passwords must be at most 72 bytes long."
+	case password != r.PostForm.Get("confirm"):
+		formError = "The passwords do not match."
+	}
+	if formError != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		renderAuthForm(w, r, "register", username, formError)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error hashing password", "err", err)
+		http.Error(w, "Error registering", http.StatusInternalServerError)
+		return
+	}
+	user, err := db.CreateUser(username, hash)
+	if errors.Is(err, store.ErrUsernameTaken) {
+		w.WriteHeader(http.StatusConflict)
+		renderAuthForm(w, r, "register", username, "That username is taken.")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating user", "err", err)
+		http.Error(w, "Error registering", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "Registered user", "user", user.Username)
+	startSession(w, r, user)
+}
+
+// logoutHandler ends the visitor's session.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := db.DeleteSession(cookie.Value); err != nil {
+			slog.ErrorContext(r.Context(), "Error deleting session", "err", err)
+		}
+	}
+	clearSessionCookie(w, r)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// renderAuthForm renders the login or registration page.
+func renderAuthForm(w http.ResponseWriter, r *http.Request, page, username, formError string) {
+	data := struct {
+		Username     string
+		Error        string
+		Return       string
+		Registration bool
+	}{
+		Username:     username,
+		Error:        formError,
+		Return:       localRedirect(r.FormValue("return")),
+		Registration: accounts.Registration,
+	}
+	render(w, r, page, data)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nithish-95/manga/backend/config"
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+// blocklistCookie stores a visitor's own blocklist as URL-encoded values
+// under the keys tag, lang and manga.
+const blocklistCookie = "blocklist"
+
+// maxBlocklistCookie bounds the encoded blocklist, leaving room for the
+// cookie's name and attributes in the 4096 bytes browsers keep per cookie.
+// It holds about 80 hidden manga, fewer than one countMatching batch.
+const maxBlocklistCookie = 3800
+
+// errBlocklistFull is returned for a blocklist too large for its cookie.
+var errBlocklistFull = errors.New("blocklist is full")
+
+// adminBlocklist is the server-wide blocklist, with tags resolved to IDs.
+var adminBlocklist mangadex.Filter
+
+// adminTagTimeout bounds resolving the blocked tag names at startup.
+const adminTagTimeout = 30 * time.Second
+
+// loadAdminBlocklist sets the server-wide blocklist from the configuration.
+// Tags may be given by ID or by English name; they are resolved once, and an
+// unknown tag or a failure to fetch the tag list is an error, so that the
+// server never runs without the exclusions it was configured with.
+func loadAdminBlocklist(ctx context.Context, content config.Content) error {
+	ctx, cancel := context.WithTimeout(ctx, adminTagTimeout)
+	defer cancel()
+	tags, err := mangadex.ResolveTagIDs(ctx, content.BlockedTags)
+	if err != nil {
+		return fmt.Errorf("content.blocked_tags: %w", err)
+	}
+	adminBlocklist = mangadex.Filter{
+		ExcludedTags:      tags,
+		ExcludedLanguages: content.BlockedLanguages,
+		ExcludedIDs:       content.BlockedManga,
+	}
+	return nil
+}
+
+// userBlocklist reads the visitor's blocklist cookie.
+func userBlocklist(r *http.Request) mangadex.Filter {
+	cookie, err := r.Cookie(blocklistCookie)
+	if err != nil {
+		return mangadex.Filter{}
+	}
+	values, err := url.ParseQuery(cookie.Value)
+	if err != nil {
+		return mangadex.Filter{}
+	}
+	return mangadex.Filter{
+		ExcludedTags:      values["tag"],
+		ExcludedLanguages: values["lang"],
+		ExcludedIDs:       values["manga"],
+	}
+}
+
+// setUserBlocklist stores the visitor's blocklist cookie, or clears it when
+// the blocklist is empty. A blocklist larger than maxBlocklistCookie is not
+// stored and errBlocklistFull is returned, rather than leaving the browser
+// to drop the cookie.
+func setUserBlocklist(w http.ResponseWriter, blocklist mangadex.Filter) error {
+	values := url.Values{
+		"tag":   blocklist.ExcludedTags,
+		"lang":  blocklist.ExcludedLanguages,
+		"manga": blocklist.ExcludedIDs,
+	}
+	if len(values.Encode()) > maxBlocklistCookie {
+		return errBlocklistFull
+	}
+	cookie := &http.Cookie{
+		Name:     blocklistCookie,
+		Value:    values.Encode(),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().AddDate(1, 0, 0),
+	}
+	if cookie.Value == "" {
+		cookie.Expires = time.Time{}
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// requestFilter builds the full listing filter for a request: the content
+// rating policy plus the server-wide and the visitor's blocklists.
+func requestFilter(r *http.Request) mangadex.Filter {
+	return contentFilter(r).Merge(adminBlocklist).Merge(userBlocklist(r))
+}
+
+// blocklistHandler shows the visitor's blocklist settings.
+func blocklistHandler(w http.ResponseWriter, r *http.Request) {
+	renderBlocklist(w, r, userBlocklist(r), "")
+}
+
+// renderBlocklist renders the blocklist settings showing blocklist, with
+// the error the last change failed with.
+func renderBlocklist(w http.ResponseWriter, r *http.Request, blocklist mangadex.Filter, formError string) {
+	tags, err := mangadex.GetTags(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching tags", "err", err)
+	}
+
+	// Group tags by their kind (genre, theme, ...) for display.
+	type tagGroup struct {
+		Name string
+		Tags []mangadex.Tag
+	}
+	var groups []tagGroup
+	for _, tag := range tags {
+		i := slices.IndexFunc(groups, func(g tagGroup) bool { return g.Name == tag.Attributes.Group })
+		if i < 0 {
+			groups = append(groups, tagGroup{Name: tag.Attributes.Group})
+			i = len(groups) - 1
+		}
+		groups[i].Tags = append(groups[i].Tags, tag)
+	}
+	for _, g := range groups {
+		slices.SortFunc(g.Tags, func(a, b mangadex.Tag) int { return strings.Compare(a.Name(), b.Name()) })
+	}
+
+	blockedTags := make(map[string]bool)
+	for _, id := range blocklist.ExcludedTags {
+		blockedTags[id] = true
+	}
+
+	data := struct {
+		TagGroups   []tagGroup
+		BlockedTags map[string]bool
+		Languages   string
+		MangaIDs    []string
+		Admin       bool
+		Error       string
+	}{
+		TagGroups:   groups,
+		BlockedTags: blockedTags,
+		Languages:   strings.Join(blocklist.ExcludedLanguages, ", "),
+		MangaIDs:    blocklist.ExcludedIDs,
+		Admin:       len(adminBlocklist.ExcludedTags)+len(adminBlocklist.ExcludedLanguages)+len(adminBlocklist.ExcludedIDs) > 0,
+		Error:       formError,
+	}
+
+	render(w, r, "blocklist", data)
+}
+
+// saveBlocklistHandler replaces the visitor's blocklist with the submitted one.
+func saveBlocklistHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	blocklist := mangadex.Filter{
+		ExcludedTags:      r.PostForm["tag"],
+		ExcludedLanguages: config.SplitList(r.PostForm.Get("languages")),
+		ExcludedIDs:       r.PostForm["manga"],
+	}
+	if err := setUserBlocklist(w, blocklist); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		renderBlocklist(w, r, blocklist, blocklistFullMessage)
+		return
+	}
+	http.Redirect(w, r, "/settings/blocklist", http.StatusSeeOther)
+}
+
+// blocklistFullMessage is shown when a change would overflow the blocklist.
+const blocklistFullMessage = "Your blocklist is full. Show some hidden manga again or unblock some tags to make room."
+
+// hideMangaHandler adds a single manga to the visitor's blocklist and
+// returns them to the page they came from.
+func hideMangaHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	mangaID := r.PostForm.Get("manga")
+	blocklist := userBlocklist(r)
+	if mangaID != "" && !slices.Contains(blocklist.ExcludedIDs, mangaID) {
+		blocklist.ExcludedIDs = append(blocklist.ExcludedIDs, mangaID)
+	}
+	if err := setUserBlocklist(w, blocklist); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		renderBlocklist(w, r, userBlocklist(r), blocklistFullMessage)
+		return
+	}
+
+	http.Redirect(w, r, localRedirect(r.PostForm.Get("return")), http.StatusSeeOther)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+// TestUserBlocklistCookie checks that a blocklist survives its cookie and
+// that one too large for a cookie is refused instead of stored.
+func TestUserBlocklistCookie(t *testing.T) {
+	manga := func(n int) []string {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+		}
+		return ids
+	}
+
+	rec := httptest.NewRecorder()
+	want := mangadex.Filter{ExcludedTags: []string{"tag1"}, ExcludedLanguages: []string{"ko"}, ExcludedIDs: manga(80)}
+	if err := setUserBlocklist(rec, want); err != nil {
+		t.Fatalf("80 hidden manga: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || len(cookies[0].String()) > 4096 {
+		t.Fatalf("cookies %v, want one of at most 4096 bytes", cookies)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	got := userBlocklist(r)
+	if !slices.Equal(got.ExcludedTags, want.ExcludedTags) || !slices.Equal(got.ExcludedLanguages, want.ExcludedLanguages) || !slices.Equal(got.ExcludedIDs, want.ExcludedIDs) {
+		t.Errorf("read back %+v, want %+v", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := setUserBlocklist(rec, mangadex.Filter{ExcludedIDs: manga(100)}); !errors.Is(err, errBlocklistFull) {
+		t.Errorf("100 hidden manga: err %v, want errBlocklistFull", err)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("full blocklist set cookies %v", cookies)
+	}
+}
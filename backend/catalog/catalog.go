@@ -0,0 +1,503 @@
+// Package catalog assembles the site's pages from a manga Source. HTML
+// handlers, JSON handlers and command-line tools all go through it, so
+// covers, statistics and content filtering are resolved the same way
+// everywhere.
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+// homeSectionSize is the number of manga in each home page section.
+const homeSectionSize = 10
+
+// homeRandomCount is the number of random picks on the home page.
+const homeRandomCount = 5
+
+// readerNeighbourWindow is how many chapters are scanned to find the
+// previous and next chapter in the reader.
+const readerNeighbourWindow = 100
+
+// ListKind selects the ordering of a List call.
+type ListKind string
+
+const (
+	Popular ListKind = "popular"
+	Recent  ListKind = "recent"
+)
+
+// RestrictedError is returned when a manga's content rating is not allowed
+// by the filter of the request.
+type RestrictedError struct {
+	Manga mangadex.Manga
+}
+
+func (e *RestrictedError) Error() string {
+	return fmt.Sprintf("manga %s is rated %s", e.Manga.ID, e.Manga.Attributes.ContentRating)
+}
+
+// Service builds page view models from a Source.
+type Service struct {
+	src Source
+}
+
+// New returns a Service reading from src.
+func New(src Source) *Service {
+	return &Service{src: src}
+}
+
+// Home returns the home page sections. Sections that fail to load are
+// logged and left empty.
+func (s *Service) Home(ctx context.Context, filter mangadex.Filter) *HomePage {
+	var home HomePage
+	var wg sync.WaitGroup
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		result, err := s.src.PopularManga(ctx, homeSectionSize, 0, filter)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error fetching popular mangas", "err", err)
+			return
+		}
+		home.Popular = result.Data
+	}()
+	go func() {
+		defer wg.Done()
+		result, err := s.src.RecentManga(ctx, homeSectionSize, 0, filter)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error fetching recently updated mangas", "err", err)
+			return
+		}
+		home.Recent = result.Data
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		home.Random, err = s.src.RandomManga(ctx, homeRandomCount, filter)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error fetching random mangas", "err", err)
+		}
+	}()
+	wg.Wait()
+
+	s.decorate(ctx, home.Popular, home.Recent, home.Random)
+	return &home
+}
+
+// Search returns a page of manga whose title matches query.
+func (s *Service) Search(ctx context.Context, query string, page, limit int, filter mangadex.Filter) (*MangaList, error) {
+	result, err := s.src.SearchManga(ctx, query, limit, (page-1)*limit, filter)
+	if err != nil {
+		return nil, err
+	}
+	return s.mangaList(ctx, fmt.Sprintf("Results for %q", query), result, page, limit), nil
+}
+
+// List returns a page of the popular or recently updated manga.
+func (s *Service) List(ctx context.Context, kind ListKind, page, limit int, filter mangadex.Filter) (*MangaList, error) {
+	var result *mangadex.MangaListResponse
+	var err error
+	var title string
+	switch kind {
+	case Popular:
+		title = "Popular Mangas"
+		result, err = s.src.PopularManga(ctx, limit, (page-1)*limit, filter)
+	case Recent:
+		title = "Recently Updated Mangas"
+		result, err = s.src.RecentManga(ctx, limit, (page-1)*limit, filter)
+	default:
+		return nil, fmt.Errorf("unknown list kind %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.mangaList(ctx, title, result, page, limit), nil
+}
+
+// Tag returns a page of the most followed manga carrying a tag.
+func (s *Service) Tag(ctx context.Context, tagID string, page, limit int, filter mangadex.Filter) (*MangaList, error) {
+	tag, err := s.src.Tag(ctx, tagID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.src.MangaByTag(ctx, tagID, limit, (page-1)*limit, filter)
+	if err != nil {
+		return nil, err
+	}
+	return s.mangaList(ctx, tag.Name(), result, page, limit), nil
+}
+
+// Tags returns every tag.
+func (s *Service) Tags(ctx context.Context) ([]mangadex.Tag, error) {
+	return s.src.Tags(ctx)
+}
+
+// Random returns count distinct random manga. A shortfall is reported
+// through Partial; it is only an error if nothing could be fetched.
+func (s *Service) Random(ctx context.Context, count int, filter mangadex.Filter) (*RandomPicks, error) {
+	mangas, err := s.src.RandomManga(ctx, count, filter)
+	var partial *mangadex.PartialError
+	if err != nil && (!errors.As(err, &partial) || len(mangas) == 0) {
+		return nil, err
+	}
+	if partial != nil {
+		slog.WarnContext(ctx, "Returning partial random mangas", "err", partial)
+	}
+
+	s.decorate(ctx, mangas)
+	return &RandomPicks{Mangas: mangas, Requested: count, Partial: partial != nil}, nil
+}
+
+// Manga returns a manga with its cover and statistics.
+func (s *Service) Manga(ctx context.Context, mangaID string, filter mangadex.Filter) (mangadex.Manga, error) {
+	manga, err := s.allowedManga(ctx, mangaID, filter)
+	if err != nil {
+		return manga, err
+	}
+	mangas := []mangadex.Manga{manga}
+	s.decorate(ctx, mangas)
+	return mangas[0], nil
+}
+
+// Mangas returns the given manga with covers and statistics, in the order
+// given. Manga that fail to load or that the filter does not allow are left
+// out; failures are logged.
+func (s *Service) Mangas(ctx context.Context, ids []string, filter mangadex.Filter) []mangadex.Manga {
+	results := make([]mangadex.Manga, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			manga, err := s.allowedManga(ctx, id, filter)
+			var restricted *RestrictedError
+			switch {
+			case errors.As(err, &restricted):
+			case err != nil:
+				slog.WarnContext(ctx, "Error fetching manga", "manga_id", id, "err", err)
+			default:
+				results[i] = manga
+			}
+		}()
+	}
+	wg.Wait()
+
+	mangas := slices.DeleteFunc(results, func(m mangadex.Manga) bool { return m.ID == "" })
+	s.decorate(ctx, mangas)
+	return mangas
+}
+
+// MangaDetail returns a manga with its cover, statistics and a page of
+// chapters. Failing to load the chapters leaves the list empty.
+func (s *Service) MangaDetail(ctx context.Context, mangaID string, page, limit int, filter mangadex.Filter) (*MangaDetail, error) {
+	manga, err := s.Manga(ctx, mangaID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	chaptersResp, err := s.src.Chapters(ctx, mangaID, limit, (page-1)*limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching chapters", "manga_id", mangaID, "err", err)
+		// If error, continue with an empty slice.
+		chaptersResp = &mangadex.ChaptersResponse{}
+	}
+
+	return &MangaDetail{
+		Manga:      manga,
+		Chapters:   chaptersResp.Data,
+		Pagination: NewPagination(page, limit, chaptersResp.Total),
+	}, nil
+}
+
+// Chapters returns a page of a manga's chapters.
+func (s *Service) Chapters(ctx context.Context, mangaID string, page, limit int, filter mangadex.Filter) (*ChapterList, error) {
+	if _, err := s.allowedManga(ctx, mangaID, filter); err != nil {
+		return nil, err
+	}
+	chaptersResp, err := s.src.Chapters(ctx, mangaID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	return &ChapterList{
+		Chapters:   chaptersResp.Data,
+		Pagination: NewPagination(page, limit, chaptersResp.Total),
+	}, nil
+}
+
+// LatestChapters returns a manga with its cover and its most recently
+// published chapters, newest first.
+func (s *Service) LatestChapters(ctx context.Context, mangaID string, limit int, filter mangadex.Filter) (mangadex.Manga, []mangadex.ChapterData, error) {
+	manga, err := s.Manga(ctx, mangaID, filter)
+	if err != nil {
+		return manga, nil, err
+	}
+	chaptersResp, err := s.src.LatestChapters(ctx, mangaID, limit)
+	if err != nil {
+		return manga, nil, err
+	}
+	return manga, chaptersResp.Data, nil
+}
+
+// Chapter returns a chapter's metadata, checking the rating of the manga
+// it belongs to.
+func (s *Service) Chapter(ctx context.Context, chapterID string, filter mangadex.Filter) (mangadex.Chapter, error) {
+	chapter, err := s.src.Chapter(ctx, chapterID)
+	if err != nil {
+		return chapter, err
+	}
+	if chapter.ID == "" {
+		return chapter, fmt.Errorf("chapter %s: %w", chapterID, mangadex.ErrNotFound)
+	}
+	if _, err := s.allowedManga(ctx, chapter.MangaID(), filter); err != nil {
+		return chapter, err
+	}
+	return chapter, nil
+}
+
+// ChapterPages returns a chapter with its page image URLs. External and
+// unavailable chapters have no pages.
+func (s *Service) ChapterPages(ctx context.Context, chapterID string, filter mangadex.Filter) (mangadex.Chapter, []string, error) {
+	chapter, err := s.Chapter(ctx, chapterID, filter)
+	if err != nil {
+		return chapter, nil, err
+	}
+	if chapter.IsExternal() || chapter.Attributes.IsUnavailable {
+		return chapter, nil, nil
+	}
+	pages, err := s.src.ChapterPages(ctx, chapterID)
+	return chapter, pages, err
+}
+
+// Volume returns the chapters of a volume of a manga with their pages, for
+// downloading. Of several releases of a chapter the first readable one is
+// kept.
+func (s *Service) Volume(ctx context.Context, mangaID, volume string, filter mangadex.Filter) (*Volume, error) {
+	manga, err := s.Manga(ctx, mangaID, filter)
+	if err != nil {
+		return nil, err
+	}
+	chapters, err := s.src.VolumeChapters(ctx, mangaID, volume)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Volume{Manga: manga, Number: volume}
+	seen := make(map[string]bool)
+	for _, c := range chapters {
+		if c.IsExternal() || c.Attributes.IsUnavailable || seen[c.Attributes.Chapter] {
+			continue
+		}
+		pages, err := s.src.ChapterPages(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("fetching pages of chapter %s: %w", c.ID, err)
+		}
+		seen[c.Attributes.Chapter] = true
+		v.Chapters = append(v.Chapters, VolumeChapter{Chapter: c, Pages: pages})
+	}
+	if len(v.Chapters) == 0 {
+		return nil, fmt.Errorf("volume %s of manga %s: %w", volume, mangaID, mangadex.ErrNotFound)
+	}
+	return v, nil
+}
+
+// Reader returns a chapter ready for reading along with its neighbours in
+// the manga named by mangaID.
+func (s *Service) Reader(ctx context.Context, mangaID, chapterID string, filter mangadex.Filter) (*ReaderPage, error) {
+	chapter, err := s.src.Chapter(ctx, chapterID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Gate on the rating of the manga the chapter actually belongs to, not
+	// the one named in the URL.
+	ownerID := chapter.MangaID()
+	if ownerID == "" {
+		ownerID = mangaID
+	}
+	if _, err := s.allowedManga(ctx, ownerID, filter); err != nil {
+		return nil, err
+	}
+
+	reader := &ReaderPage{
+		Chapter:    chapter,
+		MangaID:    mangaID,
+		MangaTitle: chapter.MangaTitle(),
+	}
+
+	// Chapters hosted on official external sites have no pages on MangaDex.
+	if chapter.IsExternal() {
+		reader.ExternalURL = chapter.Attributes.ExternalURL
+		return reader, nil
+	}
+
+	if chapter.Attributes.IsUnavailable {
+		reader.Notice = "This chapter has been made unavailable on MangaDex and cannot be read here."
+	} else {
+		reader.Pages, err = s.src.ChapterPages(ctx, chapterID)
+		if err != nil {
+			return nil, fmt.Errorf("fetching pages of chapter %s: %w", chapterID, err)
+		}
+	}
+
+	chapters, err := s.src.Chapters(ctx, mangaID, readerNeighbourWindow, 0)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching chapters", "manga_id", mangaID, "err", err)
+	} else {
+		for i, c := range chapters.Data {
+			if c.ID == chapterID {
+				if i > 0 {
+					reader.PrevChapter = chapters.Data[i-1].ID
+				}
+				if i < len(chapters.Data)-1 {
+					reader.NextChapter = chapters.Data[i+1].ID
+				}
+				break
+			}
+		}
+	}
+
+	reader.CoverURL, err = s.src.Cover(ctx, ownerID)
+	if err != nil {
+		slog.WarnContext(ctx, "Error fetching cover", "manga_id", ownerID, "err", err)
+	}
+
+	return reader, nil
+}
+
+// Author returns an author with a page of their works.
+func (s *Service) Author(ctx context.Context, authorID string, page, limit int, filter mangadex.Filter) (*AuthorPage, error) {
+	author, err := s.src.Author(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	authorPage := &AuthorPage{Author: author, Pagination: NewPagination(page, limit, 0)}
+	result, err := s.src.AuthorManga(ctx, authorID, limit, (page-1)*limit, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching author works", "author_id", authorID, "err", err)
+		return authorPage, nil
+	}
+
+	s.decorate(ctx, result.Data)
+	authorPage.Mangas = result.Data
+	authorPage.Pagination = NewPagination(page, limit, result.Total)
+	return authorPage, nil
+}
+
+// Group returns a scanlation group with a page of its latest releases.
+func (s *Service) Group(ctx context.Context, groupID string, page, limit int, filter mangadex.Filter) (*GroupPage, error) {
+	group, err := s.src.Group(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	chaptersResp, err := s.src.GroupChapters(ctx, groupID, limit, (page-1)*limit, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching group chapters", "group_id", groupID, "err", err)
+		// If error, continue with an empty slice.
+		chaptersResp = &mangadex.ChaptersResponse{}
+	}
+
+	return &GroupPage{
+		Group:      group,
+		Chapters:   chaptersResp.Data,
+		Pagination: NewPagination(page, limit, chaptersResp.Total),
+	}, nil
+}
+
+// allowedManga fetches a manga and returns a *RestrictedError if its
+// content rating is not allowed by the filter.
+func (s *Service) allowedManga(ctx context.Context, mangaID string, filter mangadex.Filter) (mangadex.Manga, error) {
+	if mangaID == "" {
+		return mangadex.Manga{}, fmt.Errorf("manga: %w", mangadex.ErrNotFound)
+	}
+	manga, err := s.src.Manga(ctx, mangaID)
+	if err != nil {
+		return manga, err
+	}
+	if manga.ID == "" {
+		return manga, fmt.Errorf("manga %s: %w", mangaID, mangadex.ErrNotFound)
+	}
+	if !filter.AllowsRating(manga.Attributes.ContentRating) {
+		return manga, &RestrictedError{Manga: manga}
+	}
+	return manga, nil
+}
+
+func (s *Service) mangaList(ctx context.Context, title string, result *mangadex.MangaListResponse, page, limit int) *MangaList {
+	s.decorate(ctx, result.Data)
+	return &MangaList{
+		Title:      title,
+		Mangas:     result.Data,
+		Pagination: NewPagination(page, limit, result.Total),
+	}
+}
+
+// decorate fills in covers and statistics for every manga in the given
+// lists.
+func (s *Service) decorate(ctx context.Context, lists ...[]mangadex.Manga) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.attachStatistics(ctx, lists...)
+	}()
+	s.attachCovers(ctx, lists...)
+	wg.Wait()
+}
+
+// attachCovers fetches the cover of every manga concurrently and stores it
+// on the manga entries.
+func (s *Service) attachCovers(ctx context.Context, lists ...[]mangadex.Manga) {
+	var coverWg sync.WaitGroup
+	for _, mangas := range lists {
+		for i := range mangas {
+			coverWg.Add(1)
+			go func(i int) {
+				defer coverWg.Done()
+				coverURL, coverErr := s.src.Cover(ctx, mangas[i].ID)
+				if coverErr != nil {
+					slog.WarnContext(ctx, "Error fetching cover", "manga_id", mangas[i].ID, "err", coverErr)
+				} else {
+					mangas[i].Attributes.CoverURL = coverURL
+				}
+			}(i)
+		}
+	}
+	coverWg.Wait()
+}
+
+// attachStatistics fetches statistics for every manga in a single batch and
+// stores them on the manga entries.
+func (s *Service) attachStatistics(ctx context.Context, lists ...[]mangadex.Manga) {
+	// Only IDs are read: covers are being attached at the same time.
+	var ids []string
+	for _, mangas := range lists {
+		for i := range mangas {
+			ids = append(ids, mangas[i].ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	stats, err := s.src.Statistics(ctx, ids...)
+	if err != nil {
+		slog.WarnContext(ctx, "Error fetching manga statistics", "err", err)
+	}
+	for _, mangas := range lists {
+		for i := range mangas {
+			if st, ok := stats[mangas[i].ID]; ok {
+				mangas[i].Statistics = &st
+			}
+		}
+	}
+}
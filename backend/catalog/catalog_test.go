@@ -0,0 +1,422 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+// fakeSource is an in-memory Source. Listings return every manga in order
+// and record the arguments they were called with; fail makes the named
+// method return an error.
+type fakeSource struct {
+	manga    []mangadex.Manga
+	chapters map[string][]mangadex.ChapterData // by manga ID
+	total    int                               // reported total of listings, len(manga) if 0
+	fail     map[string]bool
+
+	mu    sync.Mutex
+	calls []string
+}
+
+var errFake = errors.New("source failed")
+
+func (f *fakeSource) record(format string, args ...any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	call := fmt.Sprintf(format, args...)
+	f.calls = append(f.calls, call)
+	for name := range f.fail {
+		if len(call) >= len(name) && call[:len(name)] == name {
+			return errFake
+		}
+	}
+	return nil
+}
+
+func (f *fakeSource) called(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Contains(f.calls, call)
+}
+
+func (f *fakeSource) list(format string, args ...any) (*mangadex.MangaListResponse, error) {
+	if err := f.record(format, args...); err != nil {
+		return nil, err
+	}
+	total := f.total
+	if total == 0 {
+		total = len(f.manga)
+	}
+	return &mangadex.MangaListResponse{Data: slices.Clone(f.manga), Total: total}, nil
+}
+
+func (f *fakeSource) SearchManga(ctx context.Context, title string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return f.list("SearchManga %s %d %d %v", title, limit, offset, filter.ContentRatings)
+}
+
+func (f *fakeSource) PopularManga(ctx context.Context, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return f.list("PopularManga %d %d %v", limit, offset, filter.ContentRatings)
+}
+
+func (f *fakeSource) RecentManga(ctx context.Context, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return f.list("RecentManga %d %d %v", limit, offset, filter.ContentRatings)
+}
+
+func (f *fakeSource) MangaByTag(ctx context.Context, tagID string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return f.list("MangaByTag %s %d %d", tagID, limit, offset)
+}
+
+func (f *fakeSource) RandomManga(ctx context.Context, count int, filter mangadex.Filter) ([]mangadex.Manga, error) {
+	if err := f.record("RandomManga %d", count); err != nil {
+		return nil, err
+	}
+	if count > len(f.manga) {
+		return slices.Clone(f.manga), &mangadex.PartialError{Requested: count, Returned: len(f.manga)}
+	}
+	return slices.Clone(f.manga[:count]), nil
+}
+
+func (f *fakeSource) Manga(ctx context.Context, mangaID string) (mangadex.Manga, error) {
+	if err := f.record("Manga %s", mangaID); err != nil {
+		return mangadex.Manga{}, err
+	}
+	for _, m := range f.manga {
+		if m.ID == mangaID {
+			return m, nil
+		}
+	}
+	return mangadex.Manga{}, fmt.Errorf("manga %s: %w", mangaID, mangadex.ErrNotFound)
+}
+
+func (f *fakeSource) Cover(ctx context.Context, mangaID string) (string, error) {
+	if err := f.record("Cover %s", mangaID); err != nil {
+		return "", err
+	}
+	return "cover/" + mangaID, nil
+}
+
+func (f *fakeSource) Statistics(ctx context.Context, ids ...string) (map[string]mangadex.Statistics, error) {
+	if err := f.record("Statistics"); err != nil {
+		return nil, err
+	}
+	stats := make(map[string]mangadex.Statistics)
+	for i, id := range ids {
+		stats[id] = mangadex.Statistics{Follows: i + 1}
+	}
+	return stats, nil
+}
+
+func (f *fakeSource) Chapters(ctx context.Context, mangaID string, limit, offset int) (*mangadex.ChaptersResponse, error) {
+	if err := f.record("Chapters %s %d %d", mangaID, limit, offset); err != nil {
+		return nil, err
+	}
+	all := f.chapters[mangaID]
+	page := all[min(offset, len(all)):min(offset+limit, len(all))]
+	return &mangadex.ChaptersResponse{Data: page, Total: len(all)}, nil
+}
+
+func (f *fakeSource) LatestChapters(ctx context.Context, mangaID string, limit int) (*mangadex.ChaptersResponse, error) {
+	return f.Chapters(ctx, mangaID, limit, 0)
+}
+
+func (f *fakeSource) VolumeChapters(ctx context.Context, mangaID, volume string) ([]mangadex.ChapterData, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSource) Chapter(ctx context.Context, chapterID string) (mangadex.Chapter, error) {
+	if err := f.record("Chapter %s", chapterID); err != nil {
+		return mangadex.Chapter{}, err
+	}
+	for mangaID, chapters := range f.chapters {
+		for _, c := range chapters {
+			if c.ID == chapterID {
+				chapter := mangadex.Chapter{ID: c.ID, Relationships: mangadex.Relationships{{ID: mangaID, Type: "manga"}}}
+				chapter.Attributes.Chapter = c.Attributes.Chapter
+				chapter.Attributes.ExternalURL = c.Attributes.ExternalURL
+				chapter.Attributes.Pages = c.Attributes.Pages
+				chapter.Attributes.IsUnavailable = c.Attributes.IsUnavailable
+				return chapter, nil
+			}
+		}
+	}
+	return mangadex.Chapter{}, fmt.Errorf("chapter %s: %w", chapterID, mangadex.ErrNotFound)
+}
+
+func (f *fakeSource) ChapterPages(ctx context.Context, chapterID string) ([]string, error) {
+	if err := f.record("ChapterPages %s", chapterID); err != nil {
+		return nil, err
+	}
+	return []string{chapterID + "/1.png", chapterID + "/2.png"}, nil
+}
+
+func (f *fakeSource) Tags(ctx context.Context) ([]mangadex.Tag, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSource) Tag(ctx context.Context, tagID string) (mangadex.Tag, error) {
+	return mangadex.Tag{}, errors.New("not implemented")
+}
+
+func (f *fakeSource) Author(ctx context.Context, authorID string) (mangadex.Author, error) {
+	return mangadex.Author{}, errors.New("not implemented")
+}
+
+func (f *fakeSource) AuthorManga(ctx context.Context, authorID string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSource) Group(ctx context.Context, groupID string) (mangadex.Group, error) {
+	return mangadex.Group{}, errors.New("not implemented")
+}
+
+func (f *fakeSource) GroupChapters(ctx context.Context, groupID string, limit, offset int, filter mangadex.Filter) (*mangadex.ChaptersResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func testManga(id, rating string) mangadex.Manga {
+	var m mangadex.Manga
+	m.ID = id
+	m.Attributes.Title = map[string]string{"en": "Manga " + id}
+	m.Attributes.ContentRating = rating
+	return m
+}
+
+func testChapters(mangaID string, n int) []mangadex.ChapterData {
+	chapters := make([]mangadex.ChapterData, n)
+	for i := range chapters {
+		chapters[i].ID = fmt.Sprintf("%s-ch%d", mangaID, i+1)
+		chapters[i].Attributes.Chapter = fmt.Sprint(i + 1)
+		chapters[i].Attributes.Pages = 2
+	}
+	return chapters
+}
+
+// newTestService returns a Service over a source with safe manga m1 and
+// m2, erotica manga adult, and three chapters of m1 and one of adult.
+func newTestService() (*Service, *fakeSource) {
+	src := &fakeSource{
+		manga: []mangadex.Manga{
+			testManga("m1", mangadex.RatingSafe),
+			testManga("m2", mangadex.RatingSafe),
+			testManga("adult", mangadex.RatingErotica),
+		},
+		chapters: map[string][]mangadex.ChapterData{
+			"m1":    testChapters("m1", 3),
+			"adult": testChapters("adult", 1),
+		},
+		fail: make(map[string]bool),
+	}
+	return New(src), src
+}
+
+var safeOnly = mangadex.Filter{ContentRatings: []string{mangadex.RatingSafe}}
+
+func checkDecorated(t *testing.T, mangas []mangadex.Manga) {
+	t.Helper()
+	for _, m := range mangas {
+		if m.Attributes.CoverURL != "cover/"+m.ID {
+			t.Errorf("%s: cover = %q", m.ID, m.Attributes.CoverURL)
+		}
+		if m.Statistics == nil {
+			t.Errorf("%s: no statistics", m.ID)
+		}
+	}
+}
+
+func ids(mangas []mangadex.Manga) []string {
+	var ids []string
+	for _, m := range mangas {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestHome(t *testing.T) {
+	s, src := newTestService()
+	src.fail["RecentManga"] = true
+
+	home := s.Home(context.Background(), safeOnly)
+	if !src.called("PopularManga 10 0 [safe]") {
+		t.Errorf("popular section not fetched with the filter; calls: %v", src.calls)
+	}
+	if len(home.Popular) != 3 || len(home.Random) != 3 {
+		t.Errorf("got %d popular and %d random, want 3 each", len(home.Popular), len(home.Random))
+	}
+	if home.Recent != nil {
+		t.Errorf("failed section has %d manga, want none", len(home.Recent))
+	}
+	checkDecorated(t, home.Popular)
+	checkDecorated(t, home.Random)
+}
+
+func TestSearch(t *testing.T) {
+	s, src := newTestService()
+	src.total = 25
+
+	list, err := s.Search(context.Background(), "one piece", 2, 10, safeOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !src.called("SearchManga one piece 10 10 [safe]") {
+		t.Errorf("search not called with offset 10 and the filter; calls: %v", src.calls)
+	}
+	if list.Title != `Results for "one piece"` {
+		t.Errorf("title = %q", list.Title)
+	}
+	want := Pagination{Page: 2, Limit: 10, Total: 25, TotalPages: 3}
+	if list.Pagination != want {
+		t.Errorf("pagination = %+v, want %+v", list.Pagination, want)
+	}
+	if prev, next := list.Pagination.PrevPage(), list.Pagination.NextPage(); prev != 1 || next != 3 {
+		t.Errorf("prev, next = %d, %d, want 1, 3", prev, next)
+	}
+	checkDecorated(t, list.Mangas)
+
+	src.fail["SearchManga"] = true
+	if _, err := s.Search(context.Background(), "x", 1, 10, safeOnly); !errors.Is(err, errFake) {
+		t.Errorf("err = %v, want the source's error", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	s, src := newTestService()
+	src.total = 45
+
+	for _, tt := range []struct {
+		kind ListKind
+		call string
+	}{
+		{Popular, "PopularManga 20 40 [safe]"},
+		{Recent, "RecentManga 20 40 [safe]"},
+	} {
+		list, err := s.List(context.Background(), tt.kind, 3, 20, safeOnly)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.kind, err)
+		}
+		if !src.called(tt.call) {
+			t.Errorf("%s: %q not called; calls: %v", tt.kind, tt.call, src.calls)
+		}
+		if p := list.Pagination; p.TotalPages != 3 || p.PrevPage() != 2 || p.NextPage() != 0 {
+			t.Errorf("%s: pagination = %+v, prev %d, next %d", tt.kind, p, p.PrevPage(), p.NextPage())
+		}
+		checkDecorated(t, list.Mangas)
+	}
+
+	if _, err := s.List(context.Background(), "newest", 1, 20, safeOnly); err == nil {
+		t.Error("unknown list kind accepted")
+	}
+}
+
+func TestMangaDetail(t *testing.T) {
+	s, src := newTestService()
+	ctx := context.Background()
+
+	detail, err := s.MangaDetail(ctx, "m1", 2, 2, safeOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.Manga.Attributes.CoverURL != "cover/m1" || detail.Manga.Statistics == nil {
+		t.Error("manga is not decorated")
+	}
+	if len(detail.Chapters) != 1 || detail.Chapters[0].ID != "m1-ch3" {
+		t.Errorf("chapters = %+v, want only m1-ch3", detail.Chapters)
+	}
+	want := Pagination{Page: 2, Limit: 2, Total: 3, TotalPages: 2}
+	if detail.Pagination != want {
+		t.Errorf("pagination = %+v, want %+v", detail.Pagination, want)
+	}
+
+	// The rating is checked against the filter after fetching.
+	var restricted *RestrictedError
+	if _, err := s.MangaDetail(ctx, "adult", 1, 10, safeOnly); !errors.As(err, &restricted) || restricted.Manga.ID != "adult" {
+		t.Errorf("err = %v, want a RestrictedError for adult", err)
+	}
+	if _, err := s.MangaDetail(ctx, "adult", 1, 10, mangadex.Filter{ContentRatings: mangadex.ContentRatings}); err != nil {
+		t.Errorf("opted-in visitor: %v", err)
+	}
+	if _, err := s.MangaDetail(ctx, "gone", 1, 10, safeOnly); !errors.Is(err, mangadex.ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+
+	src.fail["Chapters"] = true
+	detail, err = s.MangaDetail(ctx, "m1", 1, 10, safeOnly)
+	if err != nil || len(detail.Chapters) != 0 {
+		t.Errorf("with chapters failing: %d chapters, err %v; want none and no error", len(detail.Chapters), err)
+	}
+}
+
+func TestMangasDropsFilteredAndMissing(t *testing.T) {
+	s, _ := newTestService()
+
+	mangas := s.Mangas(context.Background(), []string{"m2", "adult", "gone", "m1"}, safeOnly)
+	if got := ids(mangas); !slices.Equal(got, []string{"m2", "m1"}) {
+		t.Errorf("got %v, want [m2 m1]", got)
+	}
+	checkDecorated(t, mangas)
+}
+
+func TestReader(t *testing.T) {
+	s, src := newTestService()
+	ctx := context.Background()
+
+	reader, err := s.Reader(ctx, "m1", "m1-ch2", safeOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.PrevChapter != "m1-ch1" || reader.NextChapter != "m1-ch3" {
+		t.Errorf("neighbours = %q, %q, want m1-ch1, m1-ch3", reader.PrevChapter, reader.NextChapter)
+	}
+	if len(reader.Pages) != 2 || reader.CoverURL != "cover/m1" {
+		t.Errorf("pages = %v, cover = %q", reader.Pages, reader.CoverURL)
+	}
+
+	reader, err = s.Reader(ctx, "m1", "m1-ch1", safeOnly)
+	if err != nil || reader.PrevChapter != "" || reader.NextChapter != "m1-ch2" {
+		t.Errorf("first chapter: %+v, %v", reader, err)
+	}
+
+	// The chapter's own manga is gated, whatever manga the URL names.
+	var restricted *RestrictedError
+	if _, err := s.Reader(ctx, "m1", "adult-ch1", safeOnly); !errors.As(err, &restricted) {
+		t.Errorf("err = %v, want a RestrictedError", err)
+	}
+	if src.called("ChapterPages adult-ch1") {
+		t.Error("pages of a restricted chapter were fetched")
+	}
+
+	src.chapters["m1"][1].Attributes.Pages = 0
+	src.chapters["m1"][1].Attributes.ExternalURL = "https://example.com/ch2"
+	reader, err = s.Reader(ctx, "m1", "m1-ch2", safeOnly)
+	if err != nil || reader.ExternalURL != "https://example.com/ch2" || reader.Pages != nil {
+		t.Errorf("external chapter: %+v, %v", reader, err)
+	}
+
+	src.chapters["m1"][2].Attributes.IsUnavailable = true
+	reader, err = s.Reader(ctx, "m1", "m1-ch3", safeOnly)
+	if err != nil || reader.Notice == "" || reader.Pages != nil {
+		t.Errorf("unavailable chapter: %+v, %v", reader, err)
+	}
+}
+
+func TestRandomPartial(t *testing.T) {
+	s, src := newTestService()
+
+	picks, err := s.Random(context.Background(), 5, safeOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !picks.Partial || picks.Requested != 5 || len(picks.Mangas) != 3 {
+		t.Errorf("picks = %d of %d, partial %v; want 3 of 5, partial", len(picks.Mangas), picks.Requested, picks.Partial)
+	}
+
+	src.manga = nil
+	if _, err := s.Random(context.Background(), 5, safeOnly); err == nil {
+		t.Error("no error when nothing could be fetched")
+	}
+}
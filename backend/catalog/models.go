@@ -0,0 +1,110 @@
+package catalog
+
+import "github.com/nithish-95/manga/backend/mangadex"
+
+// Pagination describes one page of a paginated listing.
+type Pagination struct {
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	Total      int `json:"total"`
+	TotalPages int `json:"totalPages"`
+}
+
+// NewPagination computes the page count for a listing of total items.
+func NewPagination(page, limit, total int) Pagination {
+	return Pagination{Page: page, Limit: limit, Total: total, TotalPages: (total + limit - 1) / limit}
+}
+
+// PrevPage returns the previous page number, or 0 on the first page.
+func (p Pagination) PrevPage() int {
+	if p.Page > 1 {
+		return p.Page - 1
+	}
+	return 0
+}
+
+// NextPage returns the next page number, or 0 on the last page.
+func (p Pagination) NextPage() int {
+	if p.Page < p.TotalPages {
+		return p.Page + 1
+	}
+	return 0
+}
+
+// HomePage is the content of the home page.
+type HomePage struct {
+	Popular []mangadex.Manga
+	Recent  []mangadex.Manga
+	Random  []mangadex.Manga
+}
+
+// MangaList is a titled, paginated list of manga with covers and statistics.
+type MangaList struct {
+	Title      string
+	Mangas     []mangadex.Manga
+	Pagination Pagination
+}
+
+// MangaDetail is a manga with its cover, statistics and a page of chapters.
+type MangaDetail struct {
+	Manga      mangadex.Manga
+	Chapters   []mangadex.ChapterData
+	Pagination Pagination
+}
+
+// Volume is one volume of a manga with the image URLs of its chapters'
+// pages, one release per chapter number.
+type Volume struct {
+	Manga    mangadex.Manga
+	Number   string
+	Chapters []VolumeChapter
+}
+
+// VolumeChapter is a chapter of a Volume.
+type VolumeChapter struct {
+	Chapter mangadex.ChapterData
+	Pages   []string
+}
+
+// ChapterList is a page of chapters.
+type ChapterList struct {
+	Chapters   []mangadex.ChapterData
+	Pagination Pagination
+}
+
+// ReaderPage is a chapter ready for reading. For chapters hosted on an
+// external site only Chapter and ExternalURL are set; for unavailable ones
+// Notice explains why there are no pages.
+type ReaderPage struct {
+	Chapter     mangadex.Chapter
+	ExternalURL string
+	Pages       []string
+	Notice      string
+	MangaID     string
+	MangaTitle  string
+	CoverURL    string
+	PrevChapter string
+	NextChapter string
+}
+
+// RandomPicks is a set of random manga. Partial is set when fewer than
+// Requested could be fetched.
+type RandomPicks struct {
+	Mangas    []mangadex.Manga
+	Requested int
+	Partial   bool
+}
+
+// AuthorPage is an author with a page of their works.
+type AuthorPage struct {
+	Author     mangadex.Author
+	Mangas     []mangadex.Manga
+	Pagination Pagination
+}
+
+// GroupPage is a scanlation group with a page of its latest releases.
+type GroupPage struct {
+	Group      mangadex.Group
+	Chapters   []mangadex.ChapterData
+	Pagination Pagination
+}
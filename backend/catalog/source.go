@@ -0,0 +1,110 @@
+package catalog
+
+import (
+	"context"
+
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+// Source is the data the catalog is built from. MangaDex implements it
+// against the live API; tests can substitute an in-memory one.
+type Source interface {
+	SearchManga(ctx context.Context, title string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error)
+	PopularManga(ctx context.Context, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error)
+	RecentManga(ctx context.Context, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error)
+	MangaByTag(ctx context.Context, tagID string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error)
+	RandomManga(ctx context.Context, count int, filter mangadex.Filter) ([]mangadex.Manga, error)
+	Manga(ctx context.Context, mangaID string) (mangadex.Manga, error)
+	Cover(ctx context.Context, mangaID string) (string, error)
+	Statistics(ctx context.Context, ids ...string) (map[string]mangadex.Statistics, error)
+	Chapters(ctx context.Context, mangaID string, limit, offset int) (*mangadex.ChaptersResponse, error)
+	LatestChapters(ctx context.Context, mangaID string, limit int) (*mangadex.ChaptersResponse, error)
+	VolumeChapters(ctx context.Context, mangaID, volume string) ([]mangadex.ChapterData, error)
+	Chapter(ctx context.Context, chapterID string) (mangadex.Chapter, error)
+	ChapterPages(ctx context.Context, chapterID string) ([]string, error)
+	Tags(ctx context.Context) ([]mangadex.Tag, error)
+	Tag(ctx context.Context, tagID string) (mangadex.Tag, error)
+	Author(ctx context.Context, authorID string) (mangadex.Author, error)
+	AuthorManga(ctx context.Context, authorID string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error)
+	Group(ctx context.Context, groupID string) (mangadex.Group, error)
+	GroupChapters(ctx context.Context, groupID string, limit, offset int, filter mangadex.Filter) (*mangadex.ChaptersResponse, error)
+}
+
+// MangaDex is the Source backed by the mangadex package.
+type MangaDex struct{}
+
+func (MangaDex) SearchManga(ctx context.Context, title string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return mangadex.SearchMangaWithPagination(ctx, title, limit, offset, filter)
+}
+
+func (MangaDex) PopularManga(ctx context.Context, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return mangadex.GetPopularMangaWithPagination(ctx, limit, offset, filter)
+}
+
+func (MangaDex) RecentManga(ctx context.Context, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return mangadex.GetRecentlyUpdatedMangaWithPagination(ctx, limit, offset, filter)
+}
+
+func (MangaDex) MangaByTag(ctx context.Context, tagID string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return mangadex.GetMangaByTag(ctx, tagID, limit, offset, filter)
+}
+
+func (MangaDex) RandomManga(ctx context.Context, count int, filter mangadex.Filter) ([]mangadex.Manga, error) {
+	return mangadex.GetRandomMangas(ctx, count, filter)
+}
+
+func (MangaDex) Manga(ctx context.Context, mangaID string) (mangadex.Manga, error) {
+	return mangadex.GetManga(ctx, mangaID)
+}
+
+func (MangaDex) Cover(ctx context.Context, mangaID string) (string, error) {
+	return mangadex.GetCoverForManga(ctx, mangaID)
+}
+
+func (MangaDex) Statistics(ctx context.Context, ids ...string) (map[string]mangadex.Statistics, error) {
+	return mangadex.GetStatistics(ctx, ids...)
+}
+
+func (MangaDex) Chapters(ctx context.Context, mangaID string, limit, offset int) (*mangadex.ChaptersResponse, error) {
+	return mangadex.GetChaptersForManga(ctx, mangaID, limit, offset)
+}
+
+func (MangaDex) LatestChapters(ctx context.Context, mangaID string, limit int) (*mangadex.ChaptersResponse, error) {
+	return mangadex.GetLatestChapters(ctx, mangaID, limit)
+}
+
+func (MangaDex) VolumeChapters(ctx context.Context, mangaID, volume string) ([]mangadex.ChapterData, error) {
+	return mangadex.GetVolumeChapters(ctx, mangaID, volume)
+}
+
+func (MangaDex) Chapter(ctx context.Context, chapterID string) (mangadex.Chapter, error) {
+	return mangadex.GetChapterDetails(ctx, chapterID)
+}
+
+func (MangaDex) ChapterPages(ctx context.Context, chapterID string) ([]string, error) {
+	return mangadex.GetChapterPages(ctx, chapterID)
+}
+
+func (MangaDex) Tags(ctx context.Context) ([]mangadex.Tag, error) {
+	return mangadex.GetTags(ctx)
+}
+
+func (MangaDex) Tag(ctx context.Context, tagID string) (mangadex.Tag, error) {
+	return mangadex.GetTag(ctx, tagID)
+}
+
+func (MangaDex) Author(ctx context.Context, authorID string) (mangadex.Author, error) {
+	return mangadex.GetAuthor(ctx, authorID)
+}
+
+func (MangaDex) AuthorManga(ctx context.Context, authorID string, limit, offset int, filter mangadex.Filter) (*mangadex.MangaListResponse, error) {
+	return mangadex.GetAuthorManga(ctx, authorID, limit, offset, filter)
+}
+
+func (MangaDex) Group(ctx context.Context, groupID string) (mangadex.Group, error) {
+	return mangadex.GetGroup(ctx, groupID)
+}
+
+func (MangaDex) GroupChapters(ctx context.Context, groupID string, limit, offset int, filter mangadex.Filter) (*mangadex.ChaptersResponse, error) {
+	return mangadex.GetGroupChapters(ctx, groupID, limit, offset, filter)
+}
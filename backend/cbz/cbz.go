@@ -0,0 +1,140 @@
+// Package cbz writes comic book archives: ZIP files of page images in
+// reading order with a ComicInfo.xml describing them, as comic readers
+// expect.
+package cbz
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ContentType is the media type of a CBZ file.
+const ContentType = "application/vnd.comicbook+zip"
+
+// ComicInfo is the metadata of an archive in the ComicInfo.xml schema
+// (version 2.0), whose elements must keep this order. Empty fields are
+// left out.
+type ComicInfo struct {
+	XMLName         xml.Name `xml:"ComicInfo"`
+	Title           string   `xml:"Title,omitempty"`
+	Series          string   `xml:"Series,omitempty"`
+	Number          string   `xml:"Number,omitempty"`
+	Volume          int      `xml:"Volume,omitempty"`
+	Summary         string   `xml:"Summary,omitempty"`
+	Year            int      `xml:"Year,omitempty"`
+	Month           int      `xml:"Month,omitempty"`
+	Day             int      `xml:"Day,omitempty"`
+	Writer          string   `xml:"Writer,omitempty"`
+	Penciller       string   `xml:"Penciller,omitempty"`
+	Genre           string   `xml:"Genre,omitempty"`
+	Web             string   `xml:"Web,omitempty"`
+	PageCount       int      `xml:"PageCount,omitempty"`
+	LanguageISO     string   `xml:"LanguageISO,omitempty"`
+	Manga           string   `xml:"Manga,omitempty"` // "Yes" or "YesAndRightToLeft"
+	ScanInformation string   `xml:"ScanInformation,omitempty"`
+	Pages           *Pages   `xml:"Pages,omitempty"`
+}
+
+// Pages lists page details.
+type Pages struct {
+	Page []Page `xml:"Page"`
+}
+
+// Bookmark marks page image i as the start of a section, such as a
+// chapter.
+func (c *ComicInfo) Bookmark(i int, title string) {
+	if c.Pages == nil {
+		c.Pages = &Pages{}
+	}
+	c.Pages.Page = append(c.Pages.Page, Page{Image: i, Bookmark: title})
+}
+
+// Page describes one page image; readers list bookmarked pages as a table
+// of contents.
+type Page struct {
+	Image    int    `xml:"Image,attr"` // 0-based position in the archive
+	Bookmark string `xml:"Bookmark,attr,omitempty"`
+}
+
+// SetDate sets the publication date.
+func (c *ComicInfo) SetDate(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	c.Year, c.Month, c.Day = t.Year(), int(t.Month()), t.Day()
+}
+
+// Writer writes an archive to an underlying writer as pages are added, so
+// large archives can be streamed.
+type Writer struct {
+	zw    *zip.Writer
+	pages int
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// Pages returns the number of pages added so far.
+func (w *Writer) Pages() int {
+	return w.pages
+}
+
+// AddPage adds the next page image. Pages are named by their position, so
+// readers that sort by name keep them in order.
+func (w *Writer) AddPage(data []byte) error {
+	w.pages++
+	// Images are compressed already; storing them saves the CPU.
+	f, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     fmt.Sprintf("%04d%s", w.pages, extension(data)),
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// Close writes info as ComicInfo.xml and finishes the archive. It does not
+// close the underlying writer.
+func (w *Writer) Close(info ComicInfo) error {
+	f, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     "ComicInfo.xml",
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err := enc.Encode(info); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// extension returns the file extension for an image by sniffing its
+// content.
+func extension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
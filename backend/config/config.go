@@ -0,0 +1,522 @@
+// Package config loads the server configuration from a YAML file,
+// environment variables and command-line flags, in increasing order of
+// precedence.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nithish-95/manga/backend/mangadex"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration.
+type Config struct {
+	Listen        string        `yaml:"listen"`
+	Server        Server        `yaml:"server"`
+	LogLevel      string        `yaml:"log_level"`
+	LogFormat     string        `yaml:"log_format"`
+	MangaDex      MangaDex      `yaml:"mangadex"`
+	Cache         Cache         `yaml:"cache"`
+	ImageCacheDir string        `yaml:"image_cache_dir"`
+	ImageCacheMB  int           `yaml:"image_cache_mb"`
+	RateLimit     RateLimit     `yaml:"rate_limit"`
+	Languages     []string      `yaml:"languages"`
+	Content       Content       `yaml:"content"`
+	Tracing       Tracing       `yaml:"tracing"`
+	Accounts      Accounts      `yaml:"accounts"`
+	Updates       Updates       `yaml:"updates"`
+	Notifications Notifications `yaml:"notifications"`
+}
+
+// Server holds the HTTP server timeouts.
+type Server struct {
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// MangaDex holds the upstream URLs.
+type MangaDex struct {
+	APIURL   string `yaml:"api_url"`
+	CoverURL string `yaml:"cover_url"`
+}
+
+// Cache sizes the in-memory API caches.
+type Cache struct {
+	Size          int           `yaml:"size"`
+	TTL           time.Duration `yaml:"ttl"`
+	StatisticsTTL time.Duration `yaml:"statistics_ttl"`
+}
+
+// RateLimit bounds the requests made to the MangaDex API. The default stays
+// under the five requests per second MangaDex allows each client.
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// Content is the server-wide content policy.
+type Content struct {
+	Ratings          []string `yaml:"ratings"`
+	BlockedTags      []string `yaml:"blocked_tags"`
+	BlockedLanguages []string `yaml:"blocked_languages"`
+	BlockedManga     []string `yaml:"blocked_manga"` // each 100 IDs cost a request per listing
+}
+
+// Tracing configures OpenTelemetry trace export. Tracing is off while
+// Endpoint is empty.
+type Tracing struct {
+	Endpoint    string  `yaml:"otlp_endpoint"` // OTLP/HTTP collector URL, e.g. http://localhost:4318
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Accounts configures user accounts and the database they are kept in.
+type Accounts struct {
+	Database      string        `yaml:"database"`
+	SessionTTL    time.Duration `yaml:"session_ttl"`
+	SecureCookies bool          `yaml:"secure_cookies"` // set when served over HTTPS behind a proxy
+	Registration  bool          `yaml:"registration"`
+}
+
+// Updates configures the checker that polls manga in users' libraries for
+// new chapters.
+type Updates struct {
+	Interval time.Duration `yaml:"interval"` // 0 disables the checker
+}
+
+// Notifications configures how users are told about new chapters.
+type Notifications struct {
+	PublicURL      string        `yaml:"public_url"`      // site URL used in links, e.g. https://manga.example.com
+	DigestInterval time.Duration `yaml:"digest_interval"` // how long email digests collect chapters before sending
+	SMTP           SMTP          `yaml:"smtp"`
+}
+
+// SMTP is the mail server email digests are sent through. Email
+// notifications are off while Addr is empty.
+type SMTP struct {
+	Addr     string `yaml:"addr"` // host:port
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// LogLevels are the accepted values of Config.LogLevel.
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// LogFormats are the accepted values of Config.LogFormat.
+var LogFormats = []string{"text", "json"}
+
+// Default returns the configuration used when nothing is set.
+func Default() Config {
+	return Config{
+		Listen: ":3001",
+		Server: Server{
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    60 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		LogLevel:  "info",
+		LogFormat: "text",
+		MangaDex: MangaDex{
+			APIURL:   "https://api.mangadex.org",
+			CoverURL: "https://uploads.mangadex.org/covers",
+		},
+		Cache:        Cache{StatisticsTTL: 5 * time.Minute},
+		ImageCacheMB: 1024,
+		RateLimit:    RateLimit{RequestsPerSecond: 4, Burst: 4},
+		Languages:    []string{"en"},
+		Content: Content{
+			Ratings: []string{mangadex.RatingSafe, mangadex.RatingSuggestive, mangadex.RatingErotica},
+		},
+		Tracing: Tracing{SampleRatio: 1},
+		Accounts: Accounts{
+			Database:     "manga.db",
+			SessionTTL:   30 * 24 * time.Hour,
+			Registration: true,
+		},
+		Updates:       Updates{Interval: 30 * time.Minute},
+		Notifications: Notifications{DigestInterval: time.Hour},
+	}
+}
+
+// setting is a configuration value that can be given as a flag or an
+// environment variable.
+type setting struct {
+	flag, env, usage string
+	set              func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"listen", "LISTEN_ADDR", "address to listen on", func(c *Config, v string) error {
+		c.Listen = v
+		return nil
+	}},
+	{"read-timeout", "READ_TIMEOUT", "maximum time to read a request", func(c *Config, v string) error {
+		return parseDuration(v, &c.Server.ReadTimeout)
+	}},
+	{"write-timeout", "WRITE_TIMEOUT", "maximum time to write a response", func(c *Config, v string) error {
+		return parseDuration(v, &c.Server.WriteTimeout)
+	}},
+	{"idle-timeout", "IDLE_TIMEOUT", "how long idle keep-alive connections are kept open", func(c *Config, v string) error {
+		return parseDuration(v, &c.Server.IdleTimeout)
+	}},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "how long shutdown waits for requests and jobs to finish", func(c *Config, v string) error {
+		return parseDuration(v, &c.Server.ShutdownTimeout)
+	}},
+	{"log-level", "LOG_LEVEL", "log level: " + strings.Join(LogLevels, ", "), func(c *Config, v string) error {
+		c.LogLevel = v
+		return nil
+	}},
+	{"log-format", "LOG_FORMAT", "log format: " + strings.Join(LogFormats, ", "), func(c *Config, v string) error {
+		c.LogFormat = v
+		return nil
+	}},
+	{"mangadex-api-url", "MANGADEX_API_URL", "base URL of the MangaDex API", func(c *Config, v string) error {
+		c.MangaDex.APIURL = v
+		return nil
+	}},
+	{"mangadex-cover-url", "MANGADEX_COVER_URL", "base URL of MangaDex cover images", func(c *Config, v string) error {
+		c.MangaDex.CoverURL = v
+		return nil
+	}},
+	{"cache-size", "CACHE_SIZE", "maximum entries per API cache, 0 for no limit", func(c *Config, v string) error {
+		return parseInt(v, &c.Cache.Size)
+	}},
+	{"cache-ttl", "CACHE_TTL", "lifetime of cached manga, chapters and tags, 0 for ever", func(c *Config, v string) error {
+		return parseDuration(v, &c.Cache.TTL)
+	}},
+	{"statistics-ttl", "STATISTICS_TTL", "lifetime of cached manga statistics", func(c *Config, v string) error {
+		return parseDuration(v, &c.Cache.StatisticsTTL)
+	}},
+	{"image-cache-dir", "IMAGE_CACHE_DIR", "directory proxied images are cached in, empty to disable", func(c *Config, v string) error {
+		c.ImageCacheDir = v
+		return nil
+	}},
+	{"image-cache-mb", "IMAGE_CACHE_MB", "megabytes of images the image cache keeps", func(c *Config, v string) error {
+		return parseInt(v, &c.ImageCacheMB)
+	}},
+	{"rate-limit", "RATE_LIMIT", "MangaDex API requests per second, 0 for no limit while updates are off", func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		c.RateLimit.RequestsPerSecond = f
+		return nil
+	}},
+	{"rate-limit-burst", "RATE_LIMIT_BURST", "MangaDex API requests allowed at once", func(c *Config, v string) error {
+		return parseInt(v, &c.RateLimit.Burst)
+	}},
+	{"languages", "LANGUAGES", "comma-separated languages chapters are listed in", func(c *Config, v string) error {
+		c.Languages = SplitList(v)
+		return nil
+	}},
+	{"content-ratings", "CONTENT_RATINGS", "comma-separated content ratings the server allows", func(c *Config, v string) error {
+		c.Content.Ratings = SplitList(v)
+		return nil
+	}},
+	{"blocked-tags", "BLOCKED_TAGS", "comma-separated tag IDs or names hidden everywhere", func(c *Config, v string) error {
+		c.Content.BlockedTags = SplitList(v)
+		return nil
+	}},
+	{"blocked-languages", "BLOCKED_LANGUAGES", "comma-separated original languages hidden everywhere", func(c *Config, v string) error {
+		c.Content.BlockedLanguages = SplitList(v)
+		return nil
+	}},
+	{"blocked-manga", "BLOCKED_MANGA", "comma-separated manga IDs hidden everywhere", func(c *Config, v string) error {
+		c.Content.BlockedManga = SplitList(v)
+		return nil
+	}},
+	{"otlp-endpoint", "OTLP_ENDPOINT", "OTLP/HTTP collector URL traces are exported to, empty to disable", func(c *Config, v string) error {
+		c.Tracing.Endpoint = v
+		return nil
+	}},
+	{"trace-sample-ratio", "TRACE_SAMPLE_RATIO", "fraction of requests traced", func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		c.Tracing.SampleRatio = f
+		return nil
+	}},
+	{"database", "DATABASE", "database file user accounts are stored in", func(c *Config, v string) error {
+		c.Accounts.Database = v
+		return nil
+	}},
+	{"session-ttl", "SESSION_TTL", "how long a login lasts", func(c *Config, v string) error {
+		return parseDuration(v, &c.Accounts.SessionTTL)
+	}},
+	{"secure-cookies", "SECURE_COOKIES", "mark session cookies Secure, for sites served over HTTPS", func(c *Config, v string) error {
+		return parseBool(v, &c.Accounts.SecureCookies)
+	}},
+	{"registration", "REGISTRATION", "allow visitors to register accounts", func(c *Config, v string) error {
+		return parseBool(v, &c.Accounts.Registration)
+	}},
+	{"update-interval", "UPDATE_INTERVAL", "how often library manga are checked for new chapters, 0 to disable", func(c *Config, v string) error {
+		return parseDuration(v, &c.Updates.Interval)
+	}},
+	{"public-url", "PUBLIC_URL", "URL the site is reached at, used for links in notifications", func(c *Config, v string) error {
+		c.Notifications.PublicURL = v
+		return nil
+	}},
+	{"digest-interval", "DIGEST_INTERVAL", "how long email digests collect new chapters before sending", func(c *Config, v string) error {
+		return parseDuration(v, &c.Notifications.DigestInterval)
+	}},
+	{"smtp-addr", "SMTP_ADDR", "host:port of the mail server, empty to disable email", func(c *Config, v string) error {
+		c.Notifications.SMTP.Addr = v
+		return nil
+	}},
+	{"smtp-username", "SMTP_USERNAME", "mail server login", func(c *Config, v string) error {
+		c.Notifications.SMTP.Username = v
+		return nil
+	}},
+	{"smtp-password", "SMTP_PASSWORD", "mail server password", func(c *Config, v string) error {
+		c.Notifications.SMTP.Password = v
+		return nil
+	}},
+	{"smtp-from", "SMTP_FROM", "sender address of email digests", func(c *Config, v string) error {
+		c.Notifications.SMTP.From = v
+		return nil
+	}},
+}
+
+// Load builds the configuration from the defaults, the YAML file named by
+// the -config flag or CONFIG_FILE, the environment and the flags in args,
+// then validates it. PORT is still honoured for compatibility.
+func Load(name string, args []string, getenv func(string) string) (*Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("config", getenv("CONFIG_FILE"), "YAML configuration file")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		fs.Func(s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env), func(v string) error {
+			flagValues[s.flag] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w\n\nFlags:\n%s", err, usage(fs))
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.loadFile(*file); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	if port := getenv("PORT"); port != "" {
+		cfg.Listen = ":" + port
+	}
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flagValues[s.flag]; ok {
+			if err := s.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.flag, err))
+			}
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &cfg, nil
+}
+
+// usage lists the flags of fs.
+func usage(fs *flag.FlagSet) string {
+	var b strings.Builder
+	fs.SetOutput(&b)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+	return b.String()
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, port, err := net.SplitHostPort(c.Listen)
+	check(err == nil && port != "", "listen: %q is not a host:port address", c.Listen)
+	check(c.Server.ReadTimeout > 0, "server.read_timeout: must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout: must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout: must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout: must be positive")
+	check(slices.Contains(LogLevels, c.LogLevel), "log_level: %q is not one of %s", c.LogLevel, strings.Join(LogLevels, ", "))
+	check(slices.Contains(LogFormats, c.LogFormat), "log_format: %q is not one of %s", c.LogFormat, strings.Join(LogFormats, ", "))
+	check(validURL(c.MangaDex.APIURL), "mangadex.api_url: %q is not an http(s) URL", c.MangaDex.APIURL)
+	check(validURL(c.MangaDex.CoverURL), "mangadex.cover_url: %q is not an http(s) URL", c.MangaDex.CoverURL)
+	check(c.Cache.Size >= 0, "cache.size: must not be negative")
+	check(c.Cache.TTL >= 0, "cache.ttl: must not be negative")
+	check(c.Cache.StatisticsTTL > 0, "cache.statistics_ttl: must be positive")
+	check(c.ImageCacheMB > 0, "image_cache_mb: must be positive")
+	check(c.RateLimit.RequestsPerSecond >= 0, "rate_limit.requests_per_second: must not be negative")
+	check(c.RateLimit.Burst >= 1, "rate_limit.burst: must be at least 1")
+	check(len(c.Languages) > 0, "languages: at least one language is required")
+	// Visitors who have not opted in see safe content only; without it their
+	// filter would be empty, which filters nothing.
+	check(slices.Contains(c.Content.Ratings, mangadex.RatingSafe), "content.ratings: %q must be allowed", mangadex.RatingSafe)
+	for _, rating := range c.Content.Ratings {
+		check(slices.Contains(mangadex.ContentRatings, rating), "content.ratings: unknown content rating %q", rating)
+	}
+
+	if c.Tracing.Endpoint != "" {
+		check(validURL(c.Tracing.Endpoint), "tracing.otlp_endpoint: %q is not an http(s) URL", c.Tracing.Endpoint)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio: must be between 0 and 1")
+	check(c.Accounts.Database != "", "accounts.database: is required")
+	check(c.Accounts.SessionTTL > 0, "accounts.session_ttl: must be positive")
+	check(c.Updates.Interval >= 0, "updates.interval: must not be negative")
+	check(c.Updates.Interval == 0 || c.RateLimit.RequestsPerSecond > 0, "rate_limit.requests_per_second: must be positive while updates.interval polls MangaDex")
+	if c.Notifications.PublicURL != "" {
+		check(validURL(c.Notifications.PublicURL), "notifications.public_url: %q is not an http(s) URL", c.Notifications.PublicURL)
+	}
+	check(c.Notifications.DigestInterval >= 0, "notifications.digest_interval: must not be negative")
+	if smtp := c.Notifications.SMTP; smtp.Addr != "" {
+		_, port, err := net.SplitHostPort(smtp.Addr)
+		check(err == nil && port != "", "notifications.smtp.addr: %q is not a host:port address", smtp.Addr)
+		_, err = mail.ParseAddress(smtp.From)
+		check(err == nil, "notifications.smtp.from: %q is not an email address", smtp.From)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Redacted returns a copy safe to print, with credentials removed from URLs
+// and passwords.
+func (c Config) Redacted() Config {
+	c.MangaDex.APIURL = redactURL(c.MangaDex.APIURL)
+	c.MangaDex.CoverURL = redactURL(c.MangaDex.CoverURL)
+	c.Tracing.Endpoint = redactURL(c.Tracing.Endpoint)
+	if c.Notifications.SMTP.Password != "" {
+		c.Notifications.SMTP.Password = "REDACTED"
+	}
+	return c
+}
+
+// YAML encodes the configuration in the file format Load reads.
+func (c Config) YAML() ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), enc.Close()
+}
+
+// MangaDexOptions returns the options for mangadex.Configure.
+func (c *Config) MangaDexOptions() mangadex.Options {
+	return mangadex.Options{
+		APIBase:       strings.TrimSuffix(c.MangaDex.APIURL, "/"),
+		CoverBase:     strings.TrimSuffix(c.MangaDex.CoverURL, "/"),
+		Languages:     c.Languages,
+		CacheSize:     c.Cache.Size,
+		CacheTTL:      c.Cache.TTL,
+		StatisticsTTL: c.Cache.StatisticsTTL,
+		RateLimit:     c.RateLimit.RequestsPerSecond,
+		RateBurst:     c.RateLimit.Burst,
+	}
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	u.User = url.User("REDACTED")
+	return u.String()
+}
+
+func parseInt(v string, dst *int) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", v)
+	}
+	*dst = n
+	return nil
+}
+
+func parseBool(v string, dst *bool) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%q is not true or false", v)
+	}
+	*dst = b
+	return nil
+}
+
+func parseDuration(v string, dst *time.Duration) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration such as 30s or 10m", v)
+	}
+	*dst = d
+	return nil
+}
+
+// SplitList splits a comma-separated list, dropping blanks and duplicates.
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+// contentOptInCookie stores the explicit content ratings a visitor has opted
+// into after confirming their age.
+const contentOptInCookie = "content_optin"
+
+// allowedContentRatings is the server-wide content rating policy. Nothing
+// outside this list is ever requested from MangaDex or shown, regardless of
+// what a visitor opts into. It is replaced by the configured policy at
+// startup.
+var allowedContentRatings = []string{mangadex.RatingSafe, mangadex.RatingSuggestive, mangadex.RatingErotica}
+
+// parseContentRatings splits a comma-separated list of content ratings and
+// rejects unknown values.
+func parseContentRatings(value string) ([]string, error) {
+	var ratings []string
+	for _, rating := range strings.Split(value, ",") {
+		rating = strings.TrimSpace(rating)
+		if rating == "" {
+			continue
+		}
+		if !slices.Contains(mangadex.ContentRatings, rating) {
+			return nil, fmt.Errorf("unknown content rating %q", rating)
+		}
+		if !slices.Contains(ratings, rating) {
+			ratings = append(ratings, rating)
+		}
+	}
+	return ratings, nil
+}
+
+// optInRatings returns the explicit ratings the server allows visitors to
+// opt into, i.e. everything in the policy except safe.
+func optInRatings() []string {
+	var ratings []string
+	for _, rating := range allowedContentRatings {
+		if rating != mangadex.RatingSafe {
+			ratings = append(ratings, rating)
+		}
+	}
+	return ratings
+}
+
+// contentFilter builds the filter for a request: safe content plus whatever
+// the visitor opted into, capped by the server-wide policy.
+func contentFilter(r *http.Request) mangadex.Filter {
+	// The config requires safe content to be allowed, so ratings is never
+	// empty, which would filter nothing.
+	ratings := []string{mangadex.RatingSafe}
+	if cookie, err := r.Cookie(contentOptInCookie); err == nil {
+		optedIn, _ := parseContentRatings(cookie.Value)
+		for _, rating := range optInRatings() {
+			if slices.Contains(optedIn, rating) {
+				ratings = append(ratings, rating)
+			}
+		}
+	}
+	return mangadex.Filter{ContentRatings: ratings}
+}
+
+// contentGate checks a manga's content rating against the request's filter.
+// It writes a response and returns false when the manga must not be shown:
+// a 404 if the server policy forbids it, or the age-confirmation
+// interstitial if the visitor has not opted in.
+func contentGate(w http.ResponseWriter, r *http.Request, manga mangadex.Manga) bool {
+	switch contentAccess(r, manga) {
+	case http.StatusNotFound:
+		http.Error(w, "This manga is not available on this server", http.StatusNotFound)
+		return false
+	case http.StatusForbidden:
+		w.WriteHeader(http.StatusForbidden)
+		renderContentSettings(w, r, manga.GetTitle(), manga.Attributes.ContentRating, "")
+		return false
+	}
+	return true
+}
+
+// contentAccess decides whether a manga may be shown for a request. It
+// returns http.StatusOK if so, http.StatusNotFound if the server policy
+// forbids its rating, or http.StatusForbidden if the visitor has not opted in.
+func contentAccess(r *http.Request, manga mangadex.Manga) int {
+	rating := manga.Attributes.ContentRating
+	if contentFilter(r).AllowsRating(rating) {
+		return http.StatusOK
+	}
+	if rating != "" && !slices.Contains(allowedContentRatings, rating) {
+		return http.StatusNotFound
+	}
+	return http.StatusForbidden
+}
+
+// contentSettingsHandler shows the content rating settings and the
+// age-confirmation form.
+func contentSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	renderContentSettings(w, r, "", "", "")
+}
+
+// saveContentSettingsHandler stores the visitor's opt-ins after they confirm
+// their age, then returns them to the page they came from.
+func saveContentSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	var ratings []string
+	for _, rating := range r.PostForm["rating"] {
+		if slices.Contains(optInRatings(), rating) && !slices.Contains(ratings, rating) {
+			ratings = append(ratings, rating)
+		}
+	}
+	if len(ratings) > 0 && r.PostForm.Get("age_confirmed") != "on" {
+		w.WriteHeader(http.StatusBadRequest)
+		renderContentSettings(w, r, "", "", "You must confirm that you are 18 or older to enable explicit content.")
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:     contentOptInCookie,
+		Value:    strings.Join(ratings, ","),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().AddDate(1, 0, 0),
+	}
+	if len(ratings) == 0 {
+		cookie.Value = ""
+		cookie.Expires = time.Time{}
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+
+	http.Redirect(w, r, localRedirect(r.PostForm.Get("return")), http.StatusSeeOther)
+}
+
+// renderContentSettings renders the settings page. When gatedTitle is set,
+// the page is shown as an interstitial in front of that manga.
+func renderContentSettings(w http.ResponseWriter, r *http.Request, gatedTitle, gatedRating, formError string) {
+	enabled := contentFilter(r).ContentRatings
+	data := struct {
+		Ratings     []string
+		Enabled     map[string]bool
+		GatedTitle  string
+		GatedRating string
+		Error       string
+		Return      string
+	}{
+		Ratings:     optInRatings(),
+		Enabled:     make(map[string]bool),
+		GatedTitle:  gatedTitle,
+		GatedRating: gatedRating,
+		Error:       formError,
+		Return:      r.URL.RequestURI(),
+	}
+	for _, rating := range enabled {
+		data.Enabled[rating] = true
+	}
+	if r.URL.Path == "/settings/content" {
+		data.Return = localRedirect(r.FormValue("return"))
+	}
+
+	render(w, r, "content_settings", data)
+}
+
+// localRedirect returns target if it is a path on this site, or "/" so that
+// form redirects cannot send visitors elsewhere.
+func localRedirect(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || target == "" || u.IsAbs() || u.Host != "" || !strings.HasPrefix(u.Path, "/") ||
+		strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nithish-95/manga/backend/cbz"
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+// downloadConcurrency is how many page images of a download are fetched
+// ahead of the one being written.
+const downloadConcurrency = 4
+
+// downloadPageTimeout is how long each page of a download may take to fetch
+// and send. It stands in for the server's write timeout, which a whole
+// volume would outlast.
+const downloadPageTimeout = time.Minute
+
+// chapterDownloadHandler sends a chapter as a CBZ file.
+func chapterDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	chapterID := chi.URLParam(r, "chapterID")
+	chapter, pages, err := catalogService.ChapterPages(r.Context(), chapterID, requestFilter(r))
+	if err != nil {
+		catalogError(w, r, err, "chapter "+chapterID)
+		return
+	}
+	if len(pages) == 0 {
+		http.Error(w, "This chapter has no pages to download", http.StatusNotFound)
+		return
+	}
+	manga, err := catalogService.Manga(r.Context(), chapter.MangaID(), requestFilter(r))
+	if err != nil {
+		catalogError(w, r, err, "manga "+chapter.MangaID())
+		return
+	}
+
+	info := comicInfo(manga)
+	info.Title = chapter.Attributes.Title
+	info.Number = chapter.Attributes.Chapter
+	info.Volume, _ = strconv.Atoi(chapter.Attributes.Volume)
+	info.LanguageISO = chapter.Attributes.TranslatedLanguage
+	info.ScanInformation = relationshipNames(chapter.Groups())
+	info.Web = readerURL(siteURL(r), manga.ID, chapter.ID)
+	info.SetDate(chapter.Attributes.PublishAt)
+	serveCBZ(w, r, manga.GetTitle()+" - "+chapter.Heading(), pages, info)
+}
+
+// volumeDownloadHandler sends all chapters of a volume as one CBZ file,
+// bookmarking the first page of each chapter.
+func volumeDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	mangaID, number := chi.URLParam(r, "mangaID"), chi.URLParam(r, "volume")
+	volume, err := catalogService.Volume(r.Context(), mangaID, number, requestFilter(r))
+	if err != nil {
+		catalogError(w, r, err, "volume "+number+" of manga "+mangaID)
+		return
+	}
+
+	info := comicInfo(volume.Manga)
+	info.Title = "Volume " + number
+	info.Volume, _ = strconv.Atoi(number)
+	info.Web = siteURL(r) + "/manga/" + url.PathEscape(volume.Manga.ID)
+	first := volume.Chapters[0].Chapter
+	info.LanguageISO = first.Attributes.TranslatedLanguage
+	info.SetDate(first.Attributes.PublishAt)
+	var pages []string
+	var groups []mangadex.Relationship
+	seen := make(map[string]bool)
+	for _, c := range volume.Chapters {
+		info.Bookmark(len(pages), c.Chapter.Heading())
+		pages = append(pages, c.Pages...)
+		for _, g := range c.Chapter.Groups() {
+			if !seen[g.ID] {
+				seen[g.ID] = true
+				groups = append(groups, g)
+			}
+		}
+	}
+	info.ScanInformation = relationshipNames(groups)
+	serveCBZ(w, r, volume.Manga.GetTitle()+" - Vol. "+number, pages, info)
+}
+
+// comicInfo returns the metadata describing a manga as a series.
+func comicInfo(manga mangadex.Manga) cbz.ComicInfo {
+	info := cbz.ComicInfo{
+		Series:    manga.GetTitle(),
+		Summary:   manga.GetDescription(),
+		Writer:    relationshipNames(manga.Relationships.OfType("author")),
+		Penciller: relationshipNames(manga.Relationships.OfType("artist")),
+		Manga:     "Yes",
+	}
+	if manga.Attributes.OriginalLanguage == "ja" {
+		info.Manga = "YesAndRightToLeft"
+	}
+	var genres []string
+	for _, tag := range manga.Attributes.Tags {
+		genres = append(genres, tag.Name())
+	}
+	info.Genre = strings.Join(genres, ", ")
+	return info
+}
+
+func relationshipNames(rels []mangadex.Relationship) string {
+	var names []string
+	for _, rel := range rels {
+		if name := rel.Name(); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// serveCBZ streams the page images at pageURLs as a CBZ file named after
+// title, fetching them through the image cache as it goes. Once the first
+// page is sent the status can no longer change, so a page failing later
+// aborts the connection rather than ending the archive early.
+func serveCBZ(w http.ResponseWriter, r *http.Request, title string, pageURLs []string, info cbz.ComicInfo) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	results := fetchPages(ctx, pageURLs)
+	page, ok := <-results
+	if ok && page.err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching page for download", "err", page.err)
+		http.Error(w, "Error fetching pages", http.StatusBadGateway)
+		return
+	}
+
+	filename := strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, title) + ".cbz"
+	w.Header().Set("Content-Type", cbz.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	rc := http.NewResponseController(w)
+	archive := cbz.NewWriter(w)
+	for ; ok; page, ok = <-results {
+		if page.err != nil {
+			slog.ErrorContext(r.Context(), "Error fetching page for download", "err", page.err)
+			panic(http.ErrAbortHandler)
+		}
+		rc.SetWriteDeadline(time.Now().Add(downloadPageTimeout))
+		if err := archive.AddPage(page.data); err != nil {
+			return // the client went away
+		}
+	}
+	if archive.Pages() < len(pageURLs) {
+		return // cancelled
+	}
+	info.PageCount = archive.Pages()
+	if err := archive.Close(info); err != nil {
+		slog.WarnContext(r.Context(), "Error finishing download", "err", err)
+	}
+}
+
+type fetchedPage struct {
+	data []byte
+	err  error
+}
+
+// fetchPages fetches images in the background, a few at a time, and sends
+// them in order on the returned channel, which is closed after the last
+// one or once ctx is done.
+func fetchPages(ctx context.Context, urls []string) <-chan fetchedPage {
+	out := make(chan fetchedPage)
+	pending := make(chan chan fetchedPage, downloadConcurrency)
+	go func() {
+		defer close(pending)
+		for _, u := range urls {
+			result := make(chan fetchedPage, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				data, err := fetchImage(ctx, u)
+				result <- fetchedPage{data: data, err: err}
+			}()
+		}
+	}()
+	go func() {
+		defer close(out)
+		for result := range pending {
+			select {
+			case out <- <-result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,189 @@
+// Package feed writes chapter release feeds in RSS 2.0 and Atom.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+// Feed is a list of entries, newest first, independent of the format it is
+// written in.
+type Feed struct {
+	ID          string // permanent, unique identifier, a URI
+	Title       string
+	Description string
+	Link        string // the page the feed is about
+	Self        string // the feed's own URL
+	Author      string // credited for entries that name no author
+	Updated     time.Time
+	Entries     []Entry
+}
+
+// Entry is one item of a feed, such as a chapter.
+type Entry struct {
+	ID        string // permanent, unique identifier, a URI
+	Title     string
+	Link      string
+	Summary   string
+	Author    string
+	Published time.Time
+}
+
+// LastModified returns the time the feed last changed: its newest entry,
+// or Updated when that is later.
+func (f *Feed) LastModified() time.Time {
+	modified := f.Updated
+	for _, e := range f.Entries {
+		if e.Published.After(modified) {
+			modified = e.Published
+		}
+	}
+	return modified
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description,omitempty"`
+	Author      string  `xml:"dc:creator,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS encodes the feed as RSS 2.0. Entry IDs become non-permalink GUIDs.
+func (f *Feed) RSS() ([]byte, error) {
+	doc := rss{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			AtomLink:      atomLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+			LastBuildDate: rssDate(f.LastModified()),
+		},
+	}
+	for _, e := range f.Entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Summary,
+			Author:      e.Author,
+			GUID:        rssGUID{Value: e.ID},
+			PubDate:     rssDate(e.Published),
+		})
+	}
+	return encode(doc)
+}
+
+func rssDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Author   *atomPerson `xml:"author,omitempty"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Links     []atomLink  `xml:"link"`
+	Summary   string      `xml:"summary,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+// Atom encodes the feed as an Atom 1.0 feed document.
+func (f *Feed) Atom() ([]byte, error) {
+	doc := atomFeed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  atomDate(f.LastModified()),
+		Links: []atomLink{
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	if f.Author != "" {
+		doc.Author = &atomPerson{Name: f.Author}
+	}
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Updated:   atomDate(e.Published),
+			Published: atomDate(e.Published),
+			Summary:   e.Summary,
+		}
+		if e.Link != "" {
+			entry.Links = append(entry.Links, atomLink{Href: e.Link, Rel: "alternate", Type: "text/html"})
+		}
+		if e.Author != "" {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return encode(doc)
+}
+
+func atomDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func encode(doc any) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nithish-95/manga/backend/catalog"
+	"github.com/nithish-95/manga/backend/feed"
+	"github.com/nithish-95/manga/backend/store"
+)
+
+// feedSize is the number of chapters in a feed.
+const feedSize = 50
+
+// siteName titles feeds and catalogs.
+const siteName = "MangaFlow"
+
+// siteURL returns the URL the site is reached at: the configured public
+// URL, or else the scheme and host of the request.
+func siteURL(r *http.Request) string {
+	if publicURL != "" {
+		return publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// readerURL returns the absolute URL of a chapter in the reader.
+func readerURL(site, mangaID, chapterID string) string {
+	return site + "/manga/" + url.PathEscape(mangaID) + "/read/" + url.PathEscape(chapterID)
+}
+
+// tagURI returns a tag URI (RFC 4151) naming a feed of the site, for feeds
+// that are about no single manga.
+func tagURI(site, name string) string {
+	host := site
+	if u, err := url.Parse(site); err == nil {
+		host = u.Hostname()
+	}
+	return fmt.Sprintf("tag:%s,2026:%s", host, name)
+}
+
+// mangaFeedHandler serves the latest chapters of a manga as RSS, or as Atom
+// with ?format=atom.
+func mangaFeedHandler(w http.ResponseWriter, r *http.Request) {
+	mangaID := chi.URLParam(r, "mangaID")
+	manga, chapters, err := catalogService.LatestChapters(r.Context(), mangaID, feedSize, requestFilter(r))
+	if err != nil {
+		var restricted *catalog.RestrictedError
+		if errors.As(err, &restricted) {
+			// Feed readers cannot pass the content gate.
+			http.Error(w, "This manga is restricted", http.StatusForbidden)
+			return
+		}
+		catalogError(w, r, err, "manga "+mangaID)
+		return
+	}
+
+	site := siteURL(r)
+	var authors []string
+	for _, a := range manga.Authors() {
+		authors = append(authors, a.Name())
+	}
+	f := &feed.Feed{
+		ID:          "urn:uuid:" + manga.ID,
+		Title:       manga.GetTitle() + " — " + siteName,
+		Description: "New chapters of " + manga.GetTitle(),
+		Link:        site + "/manga/" + url.PathEscape(manga.ID),
+		Self:        site + r.URL.RequestURI(),
+		Author:      strings.Join(authors, ", "),
+	}
+	for _, c := range chapters {
+		link := readerURL(site, manga.ID, c.ID)
+		if c.IsExternal() {
+			link = c.Attributes.ExternalURL
+		}
+		var groups []string
+		for _, g := range c.Groups() {
+			groups = append(groups, g.Name())
+		}
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:        "urn:uuid:" + c.ID,
+			Title:     c.Heading(),
+			Link:      link,
+			Summary:   chapterSummary(manga.GetTitle(), c.Heading(), c.Attributes.TranslatedLanguage),
+			Author:    strings.Join(groups, ", "),
+			Published: c.Attributes.PublishAt,
+		})
+	}
+	// The chapters depend on the visitor's content and blocklist cookies,
+	// so shared caches must not keep the feed.
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	serveFeed(w, r, f)
+}
+
+// libraryFeedHandler serves the new chapters found for the manga in a
+// user's library. Feed readers keep no session, so the user is named by the
+// secret token in the URL instead.
+func libraryFeedHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := db.UserByFeedToken(r.URL.Query().Get("token"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Unknown feed", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading user", "err", err)
+		http.Error(w, "Error reading feed", http.StatusInternalServerError)
+		return
+	}
+	updates, _, err := db.Updates(user.ID, 0, feedSize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading updates", "err", err)
+		http.Error(w, "Error reading feed", http.StatusInternalServerError)
+		return
+	}
+
+	site := siteURL(r)
+	f := &feed.Feed{
+		ID:          tagURI(site, "library/"+user.ID),
+		Title:       user.Username + "'s library — " + siteName,
+		Description: "New chapters of the manga in " + user.Username + "'s library",
+		Link:        site + "/updates",
+		Self:        site + r.URL.RequestURI(),
+		Author:      siteName,
+	}
+	for _, u := range updates {
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:        "urn:uuid:" + u.ChapterID,
+			Title:     u.MangaTitle + " — " + u.Chapter,
+			Link:      readerURL(site, u.MangaID, u.ChapterID),
+			Summary:   chapterSummary(u.MangaTitle, u.Chapter, u.Language),
+			Published: u.Published,
+		})
+	}
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	serveFeed(w, r, f)
+}
+
+func chapterSummary(mangaTitle, chapter, language string) string {
+	summary := chapter + " of " + mangaTitle
+	if language != "" {
+		summary += " (" + strings.ToUpper(language) + ")"
+	}
+	return summary
+}
+
+// serveFeed writes f as Atom when ?format=atom is given and as RSS
+// otherwise. It answers conditional requests with 304 Not Modified using an
+// ETag of the body and the time of the newest entry.
+func serveFeed(w http.ResponseWriter, r *http.Request, f *feed.Feed) {
+	var body []byte
+	var err error
+	if r.URL.Query().Get("format") == "atom" {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		body, err = f.Atom()
+	} else {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		body, err = f.RSS()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding feed", "err", err)
+		http.Error(w, "Error encoding feed", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "", f.LastModified(), bytes.NewReader(body))
+}
+
+// libraryFeedURL returns the URL of the user's library feed, creating its
+// token the first time.
+func libraryFeedURL(r *http.Request, user *store.User) (string, error) {
+	token, err := db.LibraryFeedToken(user.ID, false)
+	if err != nil {
+		return "", err
+	}
+	return siteURL(r) + "/library/feed.xml?token=" + url.QueryEscape(token), nil
+}
+
+// resetLibraryFeedHandler replaces the user's library feed token, so that
+// the old feed URL stops working.
+func resetLibraryFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := db.LibraryFeedToken(currentUser(r).ID, true); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting library feed", "err", err)
+		http.Error(w, "Error resetting library feed", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/library", http.StatusSeeOther)
+}
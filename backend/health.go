@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/nithish-95/manga/backend/mangadex"
+)
+
+const (
+	// upstreamProbeTimeout bounds the MangaDex reachability check.
+	upstreamProbeTimeout = 2 * time.Second
+	// upstreamProbeTTL is how long a probe result is reused, so frequent
+	// readiness checks do not turn into a stream of upstream requests.
+	upstreamProbeTTL = 15 * time.Second
+)
+
+// upstreamProbe caches the result of the last MangaDex reachability check.
+var upstreamProbe struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// Readiness is the body of /readyz. Checks maps each check to "ok" or the
+// reason it failed.
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// BuildInfo is the body of /version.
+type BuildInfo struct {
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"` // commit time of the revision
+	Modified  bool   `json:"modified"`
+}
+
+// buildInfo is read once at startup; it cannot change while running.
+var buildInfo = readBuildInfo()
+
+func readBuildInfo() BuildInfo {
+	info := BuildInfo{Version: "(unknown)"}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module = bi.Main.Path
+	info.Version = bi.Main.Version
+	info.GoVersion = bi.GoVersion
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// healthzHandler reports that the process is alive.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyzHandler reports whether the server can serve traffic: templates are
+// parsed, the image cache is usable, MangaDex is reachable and the server
+// is not shutting down.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"templates": checkTemplates(),
+		"cache":     checkImageCache(),
+		"mangadex":  checkUpstream(r.Context()),
+		"shutdown":  "ok",
+	}
+	if background.Err() != nil {
+		checks["shutdown"] = "shutting down"
+	}
+
+	status, body := http.StatusOK, Readiness{Status: "ready", Checks: checks}
+	for _, result := range checks {
+		if result != "ok" {
+			status, body.Status = http.StatusServiceUnavailable, "not ready"
+		}
+	}
+	writeJSON(w, status, body)
+}
+
+// versionHandler reports the build the server is running.
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildInfo)
+}
+
+func checkTemplates() string {
+	for name, tmpl := range templates {
+		if tmpl == nil || tmpl.Lookup("base.html") == nil {
+			return "template " + name + " is not parsed"
+		}
+	}
+	if len(templates) == 0 {
+		return "no templates parsed"
+	}
+	return "ok"
+}
+
+func checkImageCache() string {
+	if images == nil {
+		return "ok"
+	}
+	if info, err := os.Stat(images.dir); err != nil {
+		return err.Error()
+	} else if !info.IsDir() {
+		return images.dir + " is not a directory"
+	}
+	return "ok"
+}
+
+// checkUpstream pings MangaDex, reusing a recent result when there is one.
+func checkUpstream(ctx context.Context) string {
+	upstreamProbe.mu.Lock()
+	defer upstreamProbe.mu.Unlock()
+
+	if time.Since(upstreamProbe.checked) > upstreamProbeTTL {
+		ctx, cancel := context.WithTimeout(ctx, upstreamProbeTimeout)
+		defer cancel()
+		upstreamProbe.err = mangadex.Ping(ctx)
+		upstreamProbe.checked = time.Now()
+	}
+	if upstreamProbe.err != nil {
+		return upstreamProbe.err.Error()
+	}
+	return "ok"
+}
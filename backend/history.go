@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nithish-95/manga/backend/catalog"
+	"github.com/nithish-95/manga/backend/store"
+)
+
+// historyPageSize is the number of entries on a page of /history.
+const historyPageSize = 100
+
+// historyDay is the history of one day.
+type historyDay struct {
+	Label   string
+	Entries []store.HistoryEntry
+}
+
+// recordHistory adds an opened chapter to the visitor's history unless they
+// are not logged in or have paused it.
+func recordHistory(r *http.Request, reader *catalog.ReaderPage) {
+	user := currentUser(r)
+	if user == nil || user.HistoryPaused {
+		return
+	}
+	e := store.HistoryEntry{
+		MangaID:    reader.MangaID,
+		MangaTitle: reader.MangaTitle,
+		ChapterID:  reader.Chapter.ID,
+		Chapter:    reader.Chapter.Heading(),
+	}
+	if err := db.AddHistory(user.ID, e); err != nil {
+		slog.WarnContext(r.Context(), "Error saving history", "chapter_id", e.ChapterID, "err", err)
+	}
+}
+
+// historyHandler shows the chapters the user opened, newest first, grouped
+// by day.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	page := pageParam(r)
+	entries, total, err := db.History(currentUser(r).ID, (page-1)*historyPageSize, historyPageSize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading history", "err", err)
+		http.Error(w, "Error reading history", http.StatusInternalServerError)
+		return
+	}
+
+	var days []historyDay
+	for _, e := range entries {
+		label := dayLabel(e.Opened.Local())
+		if len(days) == 0 || days[len(days)-1].Label != label {
+			days = append(days, historyDay{Label: label})
+		}
+		days[len(days)-1].Entries = append(days[len(days)-1].Entries, e)
+	}
+
+	data := struct {
+		Days       []historyDay
+		Paused     bool
+		Pagination catalog.Pagination
+	}{
+		Days:       days,
+		Paused:     currentUser(r).HistoryPaused,
+		Pagination: catalog.NewPagination(page, historyPageSize, total),
+	}
+	render(w, r, "history", data)
+}
+
+// dayLabel names the day of t relative to today.
+func dayLabel(t time.Time) string {
+	today := time.Now().Local()
+	switch t.Format(time.DateOnly) {
+	case today.Format(time.DateOnly):
+		return "Today"
+	case today.AddDate(0, 0, -1).Format(time.DateOnly):
+		return "Yesterday"
+	}
+	if t.Year() == today.Year() {
+		return t.Format("Monday, January 2")
+	}
+	return t.Format("Monday, January 2, 2006")
+}
+
+// deleteHistoryHandler removes the entries named by the id form field.
+func deleteHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	if err := db.DeleteHistory(currentUser(r).ID, r.PostForm["id"]...); err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting history", "err", err)
+		http.Error(w, "Error deleting history", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, localRedirect(r.PostForm.Get("return")), http.StatusSeeOther)
+}
+
+// clearHistoryHandler removes the user's whole history.
+func clearHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if err := db.ClearHistory(currentUser(r).ID); err != nil {
+		slog.ErrorContext(r.Context(), "Error clearing history", "err", err)
+		http.Error(w, "Error clearing history", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/history", http.StatusSeeOther)
+}
+
+// pauseHistoryHandler turns history recording off, or back on when the
+// paused form field is false.
+func pauseHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	paused := r.PostForm.Get("paused") != "false"
+	if err := db.SetHistoryPaused(currentUser(r).ID, paused); err != nil {
+		slog.ErrorContext(r.Context(), "Error pausing history", "err", err)
+		http.Error(w, "Error updating history settings", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/history", http.StatusSeeOther)
+}
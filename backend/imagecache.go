@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// imageCache stores proxied images on disk, keyed by a hash of their URL.
+// A nil *imageCache caches nothing.
+//
+// The cache holds at most maxBytes of images. Reading an image updates its
+// modification time, and once a write goes over the limit the images
+// unused for longest are removed until the cache is back under
+// imageCacheLowWater of it, so that evictions come in batches.
+type imageCache struct {
+	dir      string
+	maxBytes int64
+
+	mu   sync.Mutex
+	size int64 // bytes of images in dir
+}
+
+// imageCacheLowWater is the fraction of the limit eviction brings the
+// cache down to.
+const imageCacheLowWater = 0.9
+
+// newImageCache returns a cache in dir holding at most maxBytes, creating
+// it if needed. An empty dir disables caching.
+func newImageCache(dir string, maxBytes int64) (*imageCache, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &imageCache{dir: dir, maxBytes: maxBytes}
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		c.size += f.size
+	}
+	if c.size > c.maxBytes {
+		c.mu.Lock()
+		c.evict()
+		c.mu.Unlock()
+	}
+	return c, nil
+}
+
+func (c *imageCache) path(imageURL string) string {
+	sum := sha256.Sum256([]byte(imageURL))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the cached image for imageURL.
+func (c *imageCache) Get(imageURL string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	path := c.path(imageURL)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Put stores an image. The file is written under a temporary name and
+// renamed so concurrent readers never see a partial image. Images larger
+// than the whole cache are not stored.
+func (c *imageCache) Put(imageURL string, data []byte) error {
+	if c == nil || int64(len(data)) > c.maxBytes {
+		return nil
+	}
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := c.path(imageURL)
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.size += int64(len(data)) - replaced
+	if c.size > c.maxBytes {
+		c.evict()
+	}
+	return nil
+}
+
+// cachedFile is an image in the cache directory.
+type cachedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// files lists the images in the cache. Temporary files more than an hour
+// old were left behind by an interrupted Put and are removed.
+func (c *imageCache) files() ([]cachedFile, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []cachedFile
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		path := filepath.Join(c.dir, e.Name())
+		if strings.HasPrefix(e.Name(), "tmp-") {
+			if time.Since(info.ModTime()) > time.Hour {
+				os.Remove(path)
+			}
+			continue
+		}
+		files = append(files, cachedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// evict removes the least recently used images until the cache is under
+// its low-water mark. The size is recounted from the directory, which also
+// corrects it for files changed by anything else. The caller must hold mu.
+func (c *imageCache) evict() {
+	files, err := c.files()
+	if err != nil {
+		slog.Warn("Error listing image cache", "dir", c.dir, "err", err)
+		return
+	}
+	c.size = 0
+	for _, f := range files {
+		c.size += f.size
+	}
+	slices.SortFunc(files, func(a, b cachedFile) int { return a.modTime.Compare(b.modTime) })
+	target := int64(float64(c.maxBytes) * imageCacheLowWater)
+	for _, f := range files {
+		if c.size <= target {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Error evicting cached image", "path", f.path, "err", err)
+			continue
+		}
+		c.size -= f.size
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestImageCacheEviction fills a cache past its limit and checks that the
+// images used least recently are the ones removed.
+func TestImageCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newImageCache(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := bytes.Repeat([]byte("x"), 30)
+	put := func(name string, age time.Duration) {
+		t.Helper()
+		if err := c.Put(name, image); err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Add(-age)
+		if err := os.Chtimes(c.path(name), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("a", 3*time.Hour)
+	put("b", 2*time.Hour)
+	put("c", time.Hour)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a is not cached")
+	}
+
+	// 120 bytes are over the limit; dropping b, now the least recently
+	// used, brings the cache down to 90.
+	put("d", 0)
+	for name, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if _, ok := c.Get(name); ok != want {
+			t.Errorf("%s cached: %v, want %v", name, ok, want)
+		}
+	}
+	if c.size != 90 {
+		t.Errorf("size = %d, want 90", c.size)
+	}
+
+	if err := c.Put("huge", bytes.Repeat([]byte("x"), 101)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("huge"); ok {
+		t.Error("image larger than the cache was stored")
+	}
+
+	// A cache opened on the directory again counts what is there and
+	// clears out temporary files left behind.
+	stale, err := os.CreateTemp(dir, "tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Close()
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(stale.Name(), old, old)
+	c, err = newImageCache(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.size != 90 {
+		t.Errorf("reopened size = %d, want 90", c.size)
+	}
+	if _, err := os.Stat(stale.Name()); !os.IsNotExist(err) {
+		t.Errorf("stale temporary file kept: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nithish-95/manga/backend/mangadex"
+	"github.com/nithish-95/manga/backend/store"
+)
+
+// shelf is the manga on one status shelf of a library.
+type shelf struct {
+	Status store.ReadingStatus
+	Mangas []mangadex.Manga
+}
+
+// libraryHandler shows the user's library grouped by reading status.
+func libraryHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := db.Library(currentUser(r).ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading library", "err", err)
+		http.Error(w, "Error reading library", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.MangaID
+	}
+	byID := make(map[string]mangadex.Manga)
+	for _, manga := range catalogService.Mangas(r.Context(), ids, contentFilter(r)) {
+		byID[manga.ID] = manga
+	}
+
+	var shelves []shelf
+	for _, status := range store.ReadingStatuses {
+		s := shelf{Status: status}
+		for _, entry := range entries {
+			if manga, ok := byID[entry.MangaID]; ok && entry.Status == status {
+				s.Mangas = append(s.Mangas, manga)
+			}
+		}
+		if len(s.Mangas) > 0 {
+			shelves = append(shelves, s)
+		}
+	}
+
+	unread, err := db.UnreadUpdates(currentUser(r).ID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error counting unread updates", "err", err)
+	}
+	feedURL, err := libraryFeedURL(r, currentUser(r))
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error reading library feed token", "err", err)
+	}
+
+	data := struct {
+		Shelves []shelf
+		Total   int
+		Unread  map[string]int
+		FeedURL string
+	}{
+		Shelves: shelves,
+		Total:   len(entries),
+		Unread:  unread,
+		FeedURL: feedURL,
+	}
+	render(w, r, "library", data)
+}
+
+// setLibraryStatusHandler adds a manga to the user's library, or moves it
+// to another shelf, and returns them to the page they came from.
+func setLibraryStatusHandler(w http.ResponseWriter, r *http.Request) {
+	mangaID := chi.URLParam(r, "mangaID")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	status := store.ReadingStatus(r.PostForm.Get("status"))
+	if !status.Valid() {
+		http.Error(w, "Unknown reading status", http.StatusBadRequest)
+		return
+	}
+
+	// Only manga the visitor could open may be added.
+	if _, err := catalogService.Manga(r.Context(), mangaID, requestFilter(r)); err != nil {
+		catalogError(w, r, err, "manga "+mangaID)
+		return
+	}
+	if err := db.SetLibraryStatus(currentUser(r).ID, mangaID, status); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating library", "manga_id", mangaID, "err", err)
+		http.Error(w, "Error updating library", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, localRedirect(r.PostForm.Get("return")), http.StatusSeeOther)
+}
+
+// removeFromLibraryHandler removes a manga from the user's library.
+func removeFromLibraryHandler(w http.ResponseWriter, r *http.Request) {
+	mangaID := chi.URLParam(r, "mangaID")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	if err := db.RemoveFromLibrary(currentUser(r).ID, mangaID); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating library", "manga_id", mangaID, "err", err)
+		http.Error(w, "Error updating library", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, localRedirect(r.PostForm.Get("return")), http.StatusSeeOther)
+}
+
+// libraryStatus returns the shelf a manga is on in the visitor's library,
+// or "" if they are not logged in or have not added it.
+func libraryStatus(r *http.Request, mangaID string) store.ReadingStatus {
+	user := currentUser(r)
+	if user == nil {
+		return ""
+	}
+	entry, err := db.LibraryEntry(user.ID, mangaID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.WarnContext(r.Context(), "Error reading library entry", "manga_id", mangaID, "err", err)
+		}
+		return ""
+	}
+	return entry.Status
+}
@@ -1,21 +1,29 @@
 package main
 
 import (
+	"context"
 	"embed"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
-	"sync"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/nithish-95/manga/backend/catalog"
+	"github.com/nithish-95/manga/backend/config"
 	"github.com/nithish-95/manga/backend/mangadex"
+	"github.com/nithish-95/manga/backend/metrics"
+	"github.com/nithish-95/manga/backend/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var templateFiles embed.FS
@@ -27,13 +35,20 @@ var (
 	templates map[string]*template.Template
 )
 
-func init() {
-	// Pre-parse all templates on application startup.
+// parseTemplates parses all page templates. It runs when the server
+// starts rather than at package init, so tests of the handlers that do not
+// render pages need no frontend files.
+func parseTemplates() {
 	funcMap := template.FuncMap{
 		"add": func(a, b int) int {
 			return a + b
 		},
 		"safeHTML": func(s string) template.HTML { return template.HTML(s) },
+		// Bound to the request being rendered by render.
+		"currentUser":   func() *store.User { return nil },
+		"csrfField":     func() template.HTML { return "" },
+		"requestURI":    func() string { return "" },
+		"unreadUpdates": func() int { return 0 },
 	}
 
 	templates = make(map[string]*template.Template)
@@ -41,247 +56,376 @@ func init() {
 	templates["manga"] = template.Must(template.New("manga.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/manga.html"))
 	templates["reader"] = template.Must(template.New("reader.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/reader.html"))
 	templates["manga_list"] = template.Must(template.New("manga_list.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/manga_list.html"))
+	templates["author"] = template.Must(template.New("author.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/author.html"))
+	templates["content_settings"] = template.Must(template.New("content_settings.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/content_settings.html"))
+	templates["blocklist"] = template.Must(template.New("blocklist.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/blocklist.html"))
+	templates["group"] = template.Must(template.New("group.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/group.html"))
+	templates["login"] = template.Must(template.New("login.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/login.html"))
+	templates["register"] = template.Must(template.New("register.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/register.html"))
+	templates["library"] = template.Must(template.New("library.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/library.html"))
+	templates["history"] = template.Must(template.New("history.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/history.html"))
+	templates["updates"] = template.Must(template.New("updates.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/updates.html"))
+	templates["notifications"] = template.Must(template.New("notifications.html").Funcs(funcMap).ParseFS(templateFiles, "frontend/templates/base.html", "frontend/templates/notifications.html"))
 }
 
+// render executes a page template in the base layout, binding the
+// functions that describe the request: the logged-in user, the CSRF form
+// field and the page's own URI.
+func render(w http.ResponseWriter, r *http.Request, name string, data any) {
+	tmpl, err := templates[name].Clone()
+	if err == nil {
+		tmpl.Funcs(template.FuncMap{
+			"currentUser":   func() *store.User { return currentUser(r) },
+			"csrfField":     func() template.HTML { return csrfInput(r) },
+			"requestURI":    func() string { return r.URL.RequestURI() },
+			"unreadUpdates": func() int { return unreadUpdateCount(r) },
+		})
+		err = tmpl.ExecuteTemplate(w, "base.html", data)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error rendering template", "template", name, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// images caches proxied images on disk when an image cache dir is set.
+var images *imageCache
+
 func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	if command != "serve" && command != "config" {
+		fmt.Fprintf(os.Stderr, "unknown command %q; commands are serve (the default) and config\n", command)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(command, args, os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	switch command {
+	case "config":
+		printConfig(cfg)
+	default:
+		serve(cfg)
+	}
+}
+
+// printConfig writes the effective configuration, with secrets redacted,
+// in the format of the config file.
+func printConfig(cfg *config.Config) {
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(out)
+}
+
+func serve(cfg *config.Config) {
+	parseTemplates()
+	setupLogging(cfg)
+	if err := setupTracing(cfg); err != nil {
+		slog.Error("Setting up tracing", "err", err)
+		os.Exit(1)
+	}
+	mangadex.Configure(cfg.MangaDexOptions())
+	allowedContentRatings = cfg.Content.Ratings
+	if err := loadAdminBlocklist(context.Background(), cfg.Content); err != nil {
+		slog.Error("Loading blocklist", "err", err)
+		os.Exit(1)
+	}
+
+	if err := openDatabase(cfg); err != nil {
+		slog.Error("Opening database", "err", err)
+		os.Exit(1)
+	}
+	startNotifier(cfg.Notifications)
+	startUpdateChecker(cfg.Updates.Interval)
+
+	var err error
+	images, err = newImageCache(cfg.ImageCacheDir, int64(cfg.ImageCacheMB)<<20)
+	if err != nil {
+		slog.Error("Opening image cache", "err", err)
+		os.Exit(1)
+	}
+
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
+	r.Use(middleware.RequestID)
+	r.Use(traceRequests)
+	r.Use(requestLogger)
 	r.Use(middleware.Recoverer)
+	r.Use(metrics.Middleware)
 
 	// Routes
-	r.Get("/", homeHandler)
+	r.Get("/healthz", healthzHandler)
+	r.Get("/readyz", readyzHandler)
+	r.Get("/version", versionHandler)
+	r.Handle("/metrics", metrics.Handler())
 	r.Get("/image-proxy", imageProxyHandler)
-	r.Get("/manga/{mangaID}", mangaHandler)
-	r.Get("/manga/{mangaID}/read/{chapterID}", chapterHandler)
-	r.Get("/popular", popularMangaHandler)
-	r.Get("/recent", recentMangaHandler)
-	r.Get("/random-manga-json", randomMangaJSONHandler)
+	r.Get("/random-manga-json", apiRandomHandler) // kept for the home page script
+	r.Mount("/api/v1", apiRouter())
+
+	// Feeds are fetched by feed readers, which keep no session.
+	r.Get("/manga/{mangaID}/feed.xml", mangaFeedHandler)
+	r.Get("/library/feed.xml", libraryFeedHandler)
+
+	// The OPDS catalog serves e-reader apps. They keep no session cookie but
+	// may send HTTP Basic credentials for the library.
+	r.Route("/opds", func(r chi.Router) {
+		r.Use(loadUser)
+
+		r.Get("/", opdsRootHandler)
+		r.Get("/popular", opdsListHandler(catalog.Popular))
+		r.Get("/recent", opdsListHandler(catalog.Recent))
+		r.Get("/search", opdsSearchHandler)
+		r.Get("/search.xml", opdsSearchDescriptionHandler)
+		r.Get("/manga/{mangaID}", opdsMangaHandler)
+		r.Get("/chapter/{chapterID}/page/{pageNumber}", opdsPageHandler)
+		r.With(requireBasicAuth).Get("/library", opdsLibraryHandler)
+	})
+
+	// Pages know the logged-in user and their forms are CSRF protected.
+	r.Group(func(r chi.Router) {
+		r.Use(loadUser)
+		r.Use(csrfProtect)
+
+		r.Get("/", homeHandler)
+		r.Get("/manga/{mangaID}", mangaHandler)
+		r.Get("/manga/{mangaID}/read/{chapterID}", chapterHandler)
+		r.Get("/manga/{mangaID}/read/{chapterID}/download.cbz", chapterDownloadHandler)
+		r.Get("/manga/{mangaID}/volume/{volume}/download.cbz", volumeDownloadHandler)
+		r.Get("/popular", popularMangaHandler)
+		r.Get("/recent", recentMangaHandler)
+		r.Get("/author/{authorID}", authorHandler)
+		r.Get("/group/{groupID}", groupHandler)
+		r.Get("/tag/{tagID}", tagHandler)
+		r.Get("/settings/content", contentSettingsHandler)
+		r.Post("/settings/content", saveContentSettingsHandler)
+		r.Get("/settings/blocklist", blocklistHandler)
+		r.Post("/settings/blocklist", saveBlocklistHandler)
+		r.Post("/settings/blocklist/hide", hideMangaHandler)
+		r.Get("/login", loginHandler)
+		r.Post("/login", doLoginHandler)
+		r.Get("/register", registerHandler)
+		r.Post("/register", doRegisterHandler)
+		r.Post("/logout", logoutHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireUser)
+
+			r.Get("/library", libraryHandler)
+			r.Post("/library/{mangaID}", setLibraryStatusHandler)
+			r.Post("/library/{mangaID}/remove", removeFromLibraryHandler)
+			r.Post("/library/feed/reset", resetLibraryFeedHandler)
+			r.Post("/manga/{mangaID}/read/{chapterID}/progress", saveProgressHandler)
+			r.Get("/history", historyHandler)
+			r.Post("/history/delete", deleteHistoryHandler)
+			r.Post("/history/clear", clearHistoryHandler)
+			r.Post("/history/pause", pauseHistoryHandler)
+			r.Get("/updates", updatesHandler)
+			r.Post("/updates/read", markUpdatesReadHandler)
+			r.Get("/notifications", notificationsHandler)
+			r.Post("/notifications", addChannelHandler)
+			r.Post("/notifications/{channelID}/delete", deleteChannelHandler)
+			r.Post("/notifications/{channelID}/test", testChannelHandler)
+			r.Get("/notifications/{channelID}/confirm", confirmChannelHandler)
+		})
+	})
 
 	// Create a sub-filesystem for static files to remove the "frontend/public" prefix
 	staticFS, err := fs.Sub(staticFiles, "frontend/public")
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("Opening static files", "err", err)
+		os.Exit(1)
 	}
 
 	// Serve static files (CSS, JS, images)
 	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3001"
+	if err := runServer(cfg, r); err != nil {
+		slog.Error("Server error", "err", err)
+		os.Exit(1)
 	}
-
-	log.Println("Starting server on :" + port)
-	http.ListenAndServe(":"+port, r)
+	slog.Info("Server stopped")
 }
 
-// homeHandler searches for manga based on a query parameter.
+// catalogService builds every page from MangaDex.
+var catalogService = catalog.New(catalog.MangaDex{})
+
+// homeHandler shows the home page sections, or search results when a search
+// query is given.
 func homeHandler(w http.ResponseWriter, r *http.Request) {
 	searchQuery := r.URL.Query().Get("search")
+	filter := requestFilter(r)
 
 	data := struct {
-		Mangas        []mangadex.Manga
-		SearchQuery   string
-		PopularMangas []mangadex.Manga
-		RecentMangas  []mangadex.Manga
-		RandomMangas  []mangadex.Manga // Changed to slice
-		PrevPage      int
-		NextPage      int
-		TotalPages    int
+		SearchQuery string
+		Search      *catalog.MangaList
+		Home        *catalog.HomePage
+		Continue    []continueItem
 	}{
 		SearchQuery: searchQuery,
-		PrevPage:    0,
-		NextPage:    0,
-		TotalPages:  0,
 	}
 
-	var err error
-
 	if searchQuery != "" {
-		data.Mangas, err = mangadex.SearchManga(searchQuery)
+		var err error
+		data.Search, err = catalogService.Search(r.Context(), searchQuery, pageParam(r), 20, filter)
 		if err != nil {
-			log.Printf("Error searching manga: %v", err)
+			slog.ErrorContext(r.Context(), "Error searching manga", "err", err)
 			http.Error(w, "Error searching manga", http.StatusInternalServerError)
 			return
 		}
-
-		// Fetch covers for search results
-		var coverWg sync.WaitGroup
-		for i := range data.Mangas {
-			coverWg.Add(1)
-			go func(i int) {
-				defer coverWg.Done()
-				coverURL, coverErr := mangadex.GetCoverForManga(data.Mangas[i].ID)
-				if coverErr != nil {
-					log.Printf("Error fetching cover for search result manga %s: %v", data.Mangas[i].ID, coverErr)
-				} else {
-					data.Mangas[i].Attributes.CoverURL = coverURL
-				}
-			}(i)
-		}
-		coverWg.Wait()
-
 	} else {
-		var wg sync.WaitGroup
-		var popularErr, recentErr, randomErr error
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			data.PopularMangas, popularErr = mangadex.GetPopularManga()
-			if popularErr != nil {
-				log.Printf("Error fetching popular mangas: %v", popularErr)
-			}
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			data.RecentMangas, recentErr = mangadex.GetRecentlyUpdatedManga()
-			if recentErr != nil {
-				log.Printf("Error fetching recently updated mangas: %v", recentErr)
-			}
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			data.RandomMangas, randomErr = mangadex.GetRandomMangas(5) // Fetch 5 random mangas
-			if randomErr != nil {
-				log.Printf("Error fetching random mangas: %v", randomErr)
-			}
-		}()
-
-		wg.Wait()
-
-		// Fetch covers for popular mangas
-		var coverWg sync.WaitGroup
-		for i := range data.PopularMangas {
-			coverWg.Add(1)
-			go func(i int) {
-				defer coverWg.Done()
-				coverURL, coverErr := mangadex.GetCoverForManga(data.PopularMangas[i].ID)
-				if coverErr != nil {
-					log.Printf("Error fetching cover for popular manga %s: %v", data.PopularMangas[i].ID, coverErr)
-				} else {
-					data.PopularMangas[i].Attributes.CoverURL = coverURL
-				}
-			}(i)
-		}
-		coverWg.Wait()
-
-		// Fetch covers for recent mangas
-		for i := range data.RecentMangas {
-			coverWg.Add(1)
-			go func(i int) {
-				defer coverWg.Done()
-				coverURL, coverErr := mangadex.GetCoverForManga(data.RecentMangas[i].ID)
-				if coverErr != nil {
-					log.Printf("Error fetching cover for recent manga %s: %v", data.RecentMangas[i].ID, coverErr)
-				} else {
-					data.RecentMangas[i].Attributes.CoverURL = coverURL
-				}
-			}(i)
-		}
-		coverWg.Wait()
-
-		// Fetch covers for random mangas
-		for i := range data.RandomMangas {
-			coverWg.Add(1)
-			go func(i int) {
-				defer coverWg.Done()
-				coverURL, coverErr := mangadex.GetCoverForManga(data.RandomMangas[i].ID)
-				if coverErr != nil {
-					log.Printf("Error fetching cover for random manga %s: %v", data.RandomMangas[i].ID, coverErr)
-				} else {
-					data.RandomMangas[i].Attributes.CoverURL = coverURL
-				}
-			}(i)
-		}
-		coverWg.Wait()
+		data.Home = catalogService.Home(r.Context(), filter)
+		data.Continue = continueReading(r)
 	}
 
-	err = templates["home"].ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	render(w, r, "home", data)
 }
 
 // imageProxyHandler proxies image requests.
 func imageProxyHandler(w http.ResponseWriter, r *http.Request) {
 	imageURL := r.URL.Query().Get("url")
-	resp, err := http.Get(imageURL)
+	if data, ok := cachedImage(r.Context(), imageURL); ok {
+		w.Header().Set("Content-Type", http.DetectContentType(data))
+		n, _ := w.Write(data)
+		metrics.ImageProxyBytes.WithLabelValues("cache").Add(float64(n))
+		return
+	}
+
+	ctx, span := tracer.Start(r.Context(), "image proxy fetch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.full", imageURL), attribute.Bool("image.cached", images != nil)),
+	)
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
-	io.Copy(w, resp.Body)
+	if images == nil || resp.StatusCode != http.StatusOK {
+		n, _ := io.Copy(w, resp.Body)
+		metrics.ImageProxyBytes.WithLabelValues("upstream").Add(float64(n))
+		return
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := images.Put(imageURL, data); err != nil {
+		slog.WarnContext(r.Context(), "Error caching image", "url", imageURL, "err", err)
+	}
+	n, _ := w.Write(data)
+	metrics.ImageProxyBytes.WithLabelValues("upstream").Add(float64(n))
+	span.SetAttributes(attribute.Int("image.bytes", n))
 }
 
-// mangaHandler fetches and displays a single manga's details along with its chapters.
-func mangaHandler(w http.ResponseWriter, r *http.Request) {
-	mangaID := chi.URLParam(r, "mangaID")
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
+// cachedImage looks an image up in the image cache, if there is one.
+func cachedImage(ctx context.Context, imageURL string) ([]byte, bool) {
+	if images == nil {
+		return nil, false
 	}
+	data, ok := images.Get(imageURL)
+	if !ok {
+		metrics.ImageCacheLookups.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	metrics.ImageCacheLookups.WithLabelValues("hit").Inc()
+	trace.SpanFromContext(ctx).AddEvent("image cache hit")
+	return data, true
+}
 
-	limit := 10
-	offset := (page - 1) * limit
+// fetchImage returns a whole image the way the image proxy serves it: from
+// the image cache, or else fetched and cached.
+func fetchImage(ctx context.Context, imageURL string) ([]byte, error) {
+	if data, ok := cachedImage(ctx, imageURL); ok {
+		metrics.ImageProxyBytes.WithLabelValues("cache").Add(float64(len(data)))
+		return data, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "image proxy fetch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.full", imageURL), attribute.Bool("image.cached", images != nil)),
+	)
+	defer span.End()
 
-	// Fetch the manga details.
-	manga, err := mangadex.GetManga(mangaID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("fetching image %s: %s", imageURL, resp.Status)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
 
-	// Fetch cover for the manga
-	if manga.ID != "" {
-		coverURL, coverErr := mangadex.GetCoverForManga(manga.ID)
-		if coverErr != nil {
-			log.Printf("Error fetching cover for manga %s: %v", manga.ID, coverErr)
-		} else {
-			manga.Attributes.CoverURL = coverURL
-		}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
 	}
+	if err := images.Put(imageURL, data); err != nil {
+		slog.WarnContext(ctx, "Error caching image", "url", imageURL, "err", err)
+	}
+	metrics.ImageProxyBytes.WithLabelValues("upstream").Add(float64(len(data)))
+	span.SetAttributes(attribute.Int("image.bytes", len(data)))
+	return data, nil
+}
+
+// mangaHandler fetches and displays a single manga's details along with its chapters.
+func mangaHandler(w http.ResponseWriter, r *http.Request) {
+	mangaID := chi.URLParam(r, "mangaID")
 
-	// Fetch the chapters for this manga.
-	chaptersResp, err := mangadex.GetChaptersForManga(mangaID, limit, offset)
+	detail, err := catalogService.MangaDetail(r.Context(), mangaID, pageParam(r), 10, requestFilter(r))
 	if err != nil {
-		log.Printf("Error fetching chapters for manga %s: %v", mangaID, err)
-		// If error, continue with an empty slice.
-		chaptersResp = &mangadex.ChaptersResponse{}
+		catalogError(w, r, err, "manga "+mangaID)
+		return
 	}
 
-	// Prepare the data to pass to the template.
 	data := struct {
-		Manga      mangadex.Manga
-		Chapters   []mangadex.ChapterData
-		Total      int
-		Page       int
-		Limit      int
-		TotalPages int
-		BackLink   string
+		*catalog.MangaDetail
+		BackLink      string
+		LibraryStatus store.ReadingStatus
+		Statuses      []store.ReadingStatus
+		Progress      *store.Progress
+		Read          map[string]bool
 	}{
-		Manga:      manga,
-		Chapters:   chaptersResp.Data,
-		Total:      chaptersResp.Total,
-		Page:       page,
-		Limit:      limit,
-		TotalPages: (chaptersResp.Total + limit - 1) / limit,
-		BackLink:   "/",
+		MangaDetail:   detail,
+		BackLink:      "/",
+		LibraryStatus: libraryStatus(r, mangaID),
+		Statuses:      store.ReadingStatuses,
 	}
+	data.Progress, data.Read = mangaProgress(r, mangaID)
 
-	err = templates["manga"].ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	render(w, r, "manga", data)
 }
 
 // chapterHandler fetches and displays a chapter for reading.
@@ -289,186 +433,136 @@ func chapterHandler(w http.ResponseWriter, r *http.Request) {
 	mangaID := chi.URLParam(r, "mangaID")
 	chapterID := chi.URLParam(r, "chapterID")
 
-	chapter, err := mangadex.GetChapterDetails(chapterID)
+	reader, err := catalogService.Reader(r.Context(), mangaID, chapterID, requestFilter(r))
 	if err != nil {
-		log.Printf("Error getting chapter details for %s: %v", chapterID, err)
-		http.Error(w, "Failed to get chapter details", http.StatusInternalServerError)
+		catalogError(w, r, err, "chapter "+chapterID)
 		return
 	}
 
-	pages, err := mangadex.GetChapterPages(chapterID)
-	if err != nil {
-		log.Printf("Error getting chapter pages for %s: %v", chapterID, err)
-		http.Error(w, "Failed to get chapter pages", http.StatusInternalServerError)
+	// Chapters hosted on official external sites have no pages on MangaDex,
+	// so send the reader straight to the publisher instead.
+	if reader.ExternalURL != "" {
+		http.Redirect(w, r, reader.ExternalURL, http.StatusFound)
 		return
 	}
 
-	chapters, err := mangadex.GetMangaChapters(mangaID, 100, 0)
-	if err != nil {
-		log.Printf("Error fetching chapters for manga %s: %v", mangaID, err)
-	}
-
-	var prevChapter, nextChapter string
-	if chapters != nil {
-		for i, c := range chapters.Data {
-			if c.ID == chapterID {
-				if i > 0 {
-					prevChapter = chapters.Data[i-1].ID
-				}
-				if i < len(chapters.Data)-1 {
-					nextChapter = chapters.Data[i+1].ID
-				}
-				break
-			}
-		}
-	}
+	// The page to open at, from resume links.
+	startPage := min(pageParam(r), max(len(reader.Pages), 1))
+	recordProgress(r, reader, startPage)
+	recordHistory(r, reader)
+	markUpdateRead(r, reader.Chapter.ID)
 
 	data := struct {
-		Chapter     mangadex.Chapter
-		Pages       []string
-		MangaID     string
-		PrevChapter string
-		NextChapter string
-		BackLink    string
+		*catalog.ReaderPage
+		BackLink  string
+		StartPage int
 	}{
-		Chapter:     chapter,
-		Pages:       pages,
-		MangaID:     mangaID,
-		PrevChapter: prevChapter,
-		NextChapter: nextChapter,
-		BackLink:    fmt.Sprintf("/manga/%s", mangaID),
+		ReaderPage: reader,
+		BackLink:   fmt.Sprintf("/manga/%s", mangaID),
+		StartPage:  startPage,
 	}
 
-	err = templates["reader"].ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	render(w, r, "reader", data)
 }
 
 func popularMangaHandler(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
+	listHandler(w, r, catalog.Popular, "/popular")
+}
 
-	limit := 20 // Display more on the dedicated page
-	offset := (page - 1) * limit
+func recentMangaHandler(w http.ResponseWriter, r *http.Request) {
+	listHandler(w, r, catalog.Recent, "/recent")
+}
 
-	mangas, err := mangadex.GetPopularMangaWithPagination(limit, offset)
+// listHandler renders one page of a catalog listing.
+func listHandler(w http.ResponseWriter, r *http.Request, kind catalog.ListKind, baseURL string) {
+	list, err := catalogService.List(r.Context(), kind, pageParam(r), 20, requestFilter(r)) // Display more on the dedicated page
 	if err != nil {
-		log.Printf("Error fetching popular mangas: %v", err)
-		http.Error(w, "Error fetching popular mangas", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error fetching manga list", "kind", kind, "err", err)
+		http.Error(w, fmt.Sprintf("Error fetching %s mangas", kind), http.StatusInternalServerError)
 		return
 	}
+	renderMangaList(w, r, list, baseURL)
+}
+
+// tagHandler lists the most followed manga carrying a tag.
+func tagHandler(w http.ResponseWriter, r *http.Request) {
+	tagID := chi.URLParam(r, "tagID")
 
-	// Fetch covers for mangas
-	var coverWg sync.WaitGroup
-	for i := range mangas {
-		coverWg.Add(1)
-		go func(i int) {
-			defer coverWg.Done()
-			coverURL, coverErr := mangadex.GetCoverForManga(mangas[i].ID)
-			if coverErr != nil {
-				log.Printf("Error fetching cover for manga %s: %v", mangas[i].ID, coverErr)
-			} else {
-				mangas[i].Attributes.CoverURL = coverURL
-			}
-		}(i)
+	list, err := catalogService.Tag(r.Context(), tagID, pageParam(r), 20, requestFilter(r))
+	if err != nil {
+		catalogError(w, r, err, "tag "+tagID)
+		return
 	}
-	coverWg.Wait()
+	renderMangaList(w, r, list, fmt.Sprintf("/tag/%s", tagID))
+}
 
+func renderMangaList(w http.ResponseWriter, r *http.Request, list *catalog.MangaList, baseURL string) {
 	data := struct {
-		Title      string
-		Mangas     []mangadex.Manga
-		BaseURL    string
-		PrevPage   int
-		NextPage   int
-		TotalPages int
+		*catalog.MangaList
+		BaseURL string
 	}{
-		Title:    "Popular Mangas",
-		Mangas:   mangas,
-		BaseURL:  "/popular",
-		PrevPage: page - 1,
-		NextPage: page + 1,
-		// TotalPages: calculate based on total count from API if available
+		MangaList: list,
+		BaseURL:   baseURL,
 	}
 
-	err = templates["manga_list"].ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	render(w, r, "manga_list", data)
 }
 
-func recentMangaHandler(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-
-	limit := 20 // Display more on the dedicated page
-	offset := (page - 1) * limit
+// authorHandler displays an author or artist along with their works.
+func authorHandler(w http.ResponseWriter, r *http.Request) {
+	authorID := chi.URLParam(r, "authorID")
 
-	mangas, err := mangadex.GetRecentlyUpdatedMangaWithPagination(limit, offset)
+	authorPage, err := catalogService.Author(r.Context(), authorID, pageParam(r), 20, requestFilter(r))
 	if err != nil {
-		log.Printf("Error fetching recently updated mangas: %v", err)
-		http.Error(w, "Error fetching recently updated mangas", http.StatusInternalServerError)
+		catalogError(w, r, err, "author "+authorID)
 		return
 	}
 
-	// Fetch covers for mangas
-	var coverWg sync.WaitGroup
-	for i := range mangas {
-		coverWg.Add(1)
-		go func(i int) {
-			defer coverWg.Done()
-			coverURL, coverErr := mangadex.GetCoverForManga(mangas[i].ID)
-			if coverErr != nil {
-				log.Printf("Error fetching cover for manga %s: %v", mangas[i].ID, coverErr)
-			} else {
-				mangas[i].Attributes.CoverURL = coverURL
-			}
-		}(i)
-	}
-	coverWg.Wait()
-
 	data := struct {
-		Title      string
-		Mangas     []mangadex.Manga
-		BaseURL    string
-		PrevPage   int
-		NextPage   int
-		TotalPages int
+		*catalog.AuthorPage
+		BaseURL string
 	}{
-		Title:    "Recently Updated Mangas",
-		Mangas:   mangas,
-		BaseURL:  "/recent",
-		PrevPage: page - 1,
-		NextPage: page + 1,
-		// TotalPages: calculate based on total count from API if available
+		AuthorPage: authorPage,
+		BaseURL:    fmt.Sprintf("/author/%s", authorID),
 	}
 
-	err = templates["manga_list"].ExecuteTemplate(w, "base.html", data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	render(w, r, "author", data)
 }
 
-func randomMangaJSONHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	countStr := r.URL.Query().Get("limit")
-	count, err := strconv.Atoi(countStr)
-	if err != nil || count <= 0 {
-		count = 1 // Default to 1 if not specified or invalid
-	}
+// groupHandler displays a scanlation group along with its latest releases.
+func groupHandler(w http.ResponseWriter, r *http.Request) {
+	groupID := chi.URLParam(r, "groupID")
 
-	mangas, err := mangadex.GetRandomMangas(count)
+	groupPage, err := catalogService.Group(r.Context(), groupID, pageParam(r), 20, requestFilter(r))
 	if err != nil {
-		log.Printf("Error fetching random mangas: %v", err)
-		http.Error(w, "Failed to fetch random mangas", http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch random mangas"})
+		catalogError(w, r, err, "group "+groupID)
 		return
 	}
 
-	json.NewEncoder(w).Encode(mangas)
+	render(w, r, "group", groupPage)
+}
+
+// pageParam reads the page query parameter of an HTML page, defaulting to 1.
+func pageParam(r *http.Request) int {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	return page
+}
+
+// catalogError writes the HTML error response for a failed catalog call:
+// the content interstitial for restricted manga, 404 for missing ones and
+// 500 for everything else.
+func catalogError(w http.ResponseWriter, r *http.Request, err error, what string) {
+	var restricted *catalog.RestrictedError
+	switch {
+	case errors.As(err, &restricted):
+		contentGate(w, r, restricted.Manga)
+	case errors.Is(err, mangadex.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		slog.ErrorContext(r.Context(), "Error fetching "+what, "err", err)
+		http.Error(w, "Error fetching "+what, http.StatusInternalServerError)
+	}
 }
@@ -1,47 +1,127 @@
 package mangadex
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
-const (
+var (
 	apiBase      = "https://api.mangadex.org"
 	coverBaseURL = "https://uploads.mangadex.org/covers"
 )
 
+const (
+	// maxIDsPerRequest is the number of IDs the API accepts in a single
+	// ids[] or manga[] filter.
+	maxIDsPerRequest = 100
+
+	// MaxRandomMangas is the most random mangas GetRandomMangas returns.
+	MaxRandomMangas = 20
+	// randomConcurrency bounds the random manga requests in flight at once.
+	randomConcurrency = 4
+	// randomAttemptsPerManga is the request budget per requested manga,
+	// covering failures, duplicates and filtered results.
+	randomAttemptsPerManga = 3
+)
+
+// ErrNotFound is returned when the API reports that an entity does not exist.
+var ErrNotFound = errors.New("not found")
+
 var (
-	mangaCache   = NewCache[Manga]()
-	chapterCache = NewCache[*ChaptersResponse]()
+	mangaCache   = NewCache[Manga]("manga")
+	chapterCache = NewCache[*ChaptersResponse]("chapters")
+	// pagesCache keeps page URLs only briefly: they point at an
+	// at-home server that MangaDex reassigns after about 15 minutes.
+	pagesCache = NewTTLCache[[]string]("pages", pagesTTL)
+	// chapterDetailsCache keeps chapters for as long as their pages, so
+	// that a reader fetching a chapter page by page looks it up once.
+	chapterDetailsCache = NewTTLCache[Chapter]("chapter", pagesTTL)
 )
 
+// pagesTTL is how long the page URLs and details of a chapter are reused.
+const pagesTTL = 10 * time.Minute
+
 // Manga represents a manga from the API.
 type Manga struct {
 	ID         string `json:"id"`
 	Attributes struct {
-		Title       map[string]string `json:"title"`
-		Description interface{} `json:"description"` // Changed to interface{}
+		Title            map[string]string `json:"title"`
+		Description      interface{}       `json:"description"` // Changed to interface{}
+		ContentRating    string            `json:"contentRating"`
+		OriginalLanguage string            `json:"originalLanguage"`
+		Tags             []Tag             `json:"tags"`
 		// CoverURL will be populated after fetching cover data.
 		CoverURL string `json:"cover_url,omitempty"`
 	} `json:"attributes"`
-	Chapters []ChapterSummary `json:"chapters"` // Consider removing if unused.
+	Chapters      []ChapterSummary `json:"chapters"` // Consider removing if unused.
+	Relationships Relationships    `json:"relationships"`
+	// Statistics will be populated after fetching statistics data.
+	Statistics *Statistics `json:"statistics,omitempty"`
 }
 
 // GetTitle returns the English title if available, otherwise the Japanese title, or the first available title.
 func (m Manga) GetTitle() string {
-	if title, ok := m.Attributes.Title["en"]; ok && title != "" {
+	return preferredTitle(m.Attributes.Title)
+}
+
+// Authors returns the authors and artists of the manga, each listed once.
+func (m Manga) Authors() []Relationship {
+	var authors []Relationship
+	seen := make(map[string]bool)
+	for _, rel := range m.Relationships.OfType("author", "artist") {
+		if !seen[rel.ID] {
+			seen[rel.ID] = true
+			authors = append(authors, rel)
+		}
+	}
+	return authors
+}
+
+// RelatedManga returns the sequels, prequels, spin-offs, adaptations and
+// other series linked to the manga.
+func (m Manga) RelatedManga() []Relationship {
+	return m.Relationships.OfType("manga")
+}
+
+// GetDescription returns the English description if available, otherwise
+// any non-empty one, or "" when the manga has none.
+func (m Manga) GetDescription() string {
+	switch d := m.Attributes.Description.(type) {
+	case string:
+		return d
+	case map[string]interface{}:
+		if en, ok := d["en"].(string); ok && en != "" {
+			return en
+		}
+		for _, v := range d {
+			if s, ok := v.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// preferredTitle picks the English entry of a localized title map, falling
+// back to Japanese and then to any non-empty entry.
+func preferredTitle(titles map[string]string) string {
+	if title, ok := titles["en"]; ok && title != "" {
 		return title
 	}
-	if title, ok := m.Attributes.Title["ja"]; ok && title != "" {
+	if title, ok := titles["ja"]; ok && title != "" {
 		return title
 	}
 	// Fallback to any available title if en or ja are not present
-	for _, title := range m.Attributes.Title {
+	for _, title := range titles {
 		if title != "" {
 			return title
 		}
@@ -49,6 +129,75 @@ func (m Manga) GetTitle() string {
 	return "Untitled"
 }
 
+// Relationship is a reference from one API entity to another. Attributes are
+// only populated when the related type was requested with includes[].
+type Relationship struct {
+	ID         string                  `json:"id"`
+	Type       string                  `json:"type"`
+	Related    string                  `json:"related,omitempty"`
+	Attributes *RelationshipAttributes `json:"attributes,omitempty"`
+}
+
+// Name returns the display name of the related entity: a group or author
+// name, a username or a manga title. It is empty unless the relationship was
+// expanded.
+func (r Relationship) Name() string {
+	switch {
+	case r.Attributes == nil:
+		return ""
+	case r.Attributes.Name != "":
+		return r.Attributes.Name
+	case r.Attributes.Username != "":
+		return r.Attributes.Username
+	case r.Attributes.Title != nil:
+		return preferredTitle(r.Attributes.Title)
+	}
+	return ""
+}
+
+// RelationLabel formats the kind of a manga-to-manga relationship, e.g.
+// "spin_off" becomes "Spin off".
+func (r Relationship) RelationLabel() string {
+	label := strings.ReplaceAll(r.Related, "_", " ")
+	if label == "" {
+		return "Related"
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// Relationships is the list of references attached to an API entity.
+type Relationships []Relationship
+
+// OfType returns the relationships of the given type, in API order.
+func (rs Relationships) OfType(types ...string) []Relationship {
+	var out []Relationship
+	for _, rel := range rs {
+		if slices.Contains(types, rel.Type) {
+			out = append(out, rel)
+		}
+	}
+	return out
+}
+
+// First returns the first relationship of the given type, or the zero value.
+func (rs Relationships) First(relType string) Relationship {
+	for _, rel := range rs {
+		if rel.Type == relType {
+			return rel
+		}
+	}
+	return Relationship{}
+}
+
+// RelationshipAttributes holds the subset of expanded attributes we use from
+// the related entity types.
+type RelationshipAttributes struct {
+	Name     string            `json:"name,omitempty"`     // scanlation_group, author, artist
+	Username string            `json:"username,omitempty"` // user
+	Title    map[string]string `json:"title,omitempty"`    // manga
+	FileName string            `json:"fileName,omitempty"` // cover_art
+}
+
 // ChapterSummary represents basic chapter info.
 type ChapterSummary struct {
 	ID    string `json:"id"`
@@ -59,8 +208,69 @@ type ChapterSummary struct {
 type Chapter struct {
 	ID         string `json:"id"`
 	Attributes struct {
-		Title string `json:"title"`
+		Chapter            string    `json:"chapter"`
+		Volume             string    `json:"volume"`
+		Title              string    `json:"title"`
+		TranslatedLanguage string    `json:"translatedLanguage"`
+		ExternalURL        string    `json:"externalUrl"`
+		Pages              int       `json:"pages"`
+		PublishAt          time.Time `json:"publishAt"`
+		ReadableAt         time.Time `json:"readableAt"`
+		IsUnavailable      bool      `json:"isUnavailable"`
 	} `json:"attributes"`
+	Relationships Relationships `json:"relationships"`
+}
+
+// IsExternal reports whether the chapter is hosted on an external site
+// instead of MangaDex, in which case it has no pages to read here.
+func (c Chapter) IsExternal() bool {
+	return c.Attributes.ExternalURL != "" && c.Attributes.Pages == 0
+}
+
+// Heading formats the chapter as "Vol. 3 Ch. 21 — Title", omitting the parts
+// that are not set. Oneshots without a number are labelled as such.
+func (c Chapter) Heading() string {
+	return chapterHeading(c.Attributes.Volume, c.Attributes.Chapter, c.Attributes.Title)
+}
+
+func chapterHeading(volume, chapter, title string) string {
+	var parts []string
+	if volume != "" {
+		parts = append(parts, "Vol. "+volume)
+	}
+	if chapter != "" {
+		parts = append(parts, "Ch. "+chapter)
+	}
+	heading := strings.Join(parts, " ")
+	switch {
+	case heading == "" && title == "":
+		return "Oneshot"
+	case heading == "":
+		return title
+	case title != "":
+		return heading + " — " + title
+	}
+	return heading
+}
+
+// Groups returns the scanlation groups credited for the chapter.
+func (c Chapter) Groups() []Relationship {
+	return c.Relationships.OfType("scanlation_group")
+}
+
+// Uploader returns the username of the user who uploaded the chapter.
+func (c Chapter) Uploader() string {
+	return c.Relationships.First("user").Name()
+}
+
+// MangaID returns the ID of the manga the chapter belongs to.
+func (c Chapter) MangaID() string {
+	return c.Relationships.First("manga").ID
+}
+
+// MangaTitle returns the title of the manga the chapter belongs to.
+func (c Chapter) MangaTitle() string {
+	return c.Relationships.First("manga").Name()
 }
 
 // CoverData represents one cover entry returned by the API.
@@ -69,10 +279,7 @@ type CoverData struct {
 	Attributes struct {
 		FileName string `json:"fileName"`
 	} `json:"attributes"`
-	Relationships []struct {
-		ID   string `json:"id"`
-		Type string `json:"type"`
-	} `json:"relationships"`
+	Relationships Relationships `json:"relationships"`
 }
 
 // CoverResponse is the API response for cover requests.
@@ -92,10 +299,44 @@ type ChaptersResponse struct {
 type ChapterData struct {
 	ID         string `json:"id"`
 	Attributes struct {
-		Chapter string `json:"chapter"` // chapter number as string (may be empty)
-		Title   string `json:"title"`
-		Volume  string `json:"volume"`
+		Chapter            string    `json:"chapter"` // chapter number as string (may be empty)
+		Title              string    `json:"title"`
+		Volume             string    `json:"volume"`
+		ExternalURL        string    `json:"externalUrl"` // set when the chapter is hosted on an official external site
+		Pages              int       `json:"pages"`
+		TranslatedLanguage string    `json:"translatedLanguage"`
+		PublishAt          time.Time `json:"publishAt"`
+		ReadableAt         time.Time `json:"readableAt"`
+		IsUnavailable      bool      `json:"isUnavailable"`
 	} `json:"attributes"`
+	Relationships Relationships `json:"relationships"`
+}
+
+// IsExternal reports whether the chapter is hosted on an external site
+// instead of MangaDex, in which case it has no pages to read here.
+func (c ChapterData) IsExternal() bool {
+	return c.Attributes.ExternalURL != "" && c.Attributes.Pages == 0
+}
+
+// Heading formats the chapter like Chapter.Heading.
+func (c ChapterData) Heading() string {
+	return chapterHeading(c.Attributes.Volume, c.Attributes.Chapter, c.Attributes.Title)
+}
+
+// Groups returns the scanlation groups credited for the chapter.
+func (c ChapterData) Groups() []Relationship {
+	return c.Relationships.OfType("scanlation_group")
+}
+
+// MangaID returns the ID of the manga the chapter belongs to.
+func (c ChapterData) MangaID() string {
+	return c.Relationships.First("manga").ID
+}
+
+// MangaTitle returns the title of the manga the chapter belongs to, when the
+// manga relationship was expanded.
+func (c ChapterData) MangaTitle() string {
+	return c.Relationships.First("manga").Name()
 }
 
 // AtHomeServerResponse represents the response from the /at-home/server/{chapter_id} endpoint.
@@ -110,7 +351,8 @@ type AtHomeServerResponse struct {
 
 // httpClient with timeout for requests.
 var httpClient = &http.Client{
-	Timeout: 30 * time.Second,
+	Timeout:   30 * time.Second,
+	Transport: &instrumentedTransport{next: http.DefaultTransport},
 }
 
 type MangaListResponse struct {
@@ -119,83 +361,205 @@ type MangaListResponse struct {
 	Total  int     `json:"total"`
 }
 
+// checkStatus turns a non-OK API response into an error, wrapping
+// ErrNotFound for 404 responses.
+func checkStatus(resp *http.Response, what string) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%s: %w", what, ErrNotFound)
+	}
+	return fmt.Errorf("API returned non-OK status: %s", resp.Status)
+}
+
+// get performs a GET request against the API. Requests are logged at debug
+// level through the context, so they carry the caller's request ID.
+func get(ctx context.Context, requestURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "Requesting URL", "url", requestURL)
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "MangaDex request failed", "url", requestURL, "err", err)
+		return nil, err
+	}
+	slog.DebugContext(ctx, "MangaDex response", "url", requestURL, "status", resp.StatusCode, "duration", time.Since(start))
+	return resp, nil
+}
+
+// Ping checks that the API is reachable and answering.
+func Ping(ctx context.Context) error {
+	resp, err := get(ctx, apiBase+"/ping")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp, "ping")
+}
+
 // GetMangaList fetches a list of manga based on provided parameters.
-func GetMangaList(params url.Values) ([]Manga, error) {
+func GetMangaList(ctx context.Context, params url.Values) ([]Manga, error) {
+	result, err := GetMangaListPage(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// GetMangaListPage fetches a page of manga along with the total number of
+// results, for callers that paginate.
+func GetMangaListPage(ctx context.Context, params url.Values) (*MangaListResponse, error) {
 	requestURL := fmt.Sprintf("%s/manga?%s", apiBase, params.Encode())
-	log.Printf("Requesting URL: %s", requestURL)
-	resp, err := httpClient.Get(requestURL)
+	resp, err := get(ctx, requestURL)
 	if err != nil {
-		log.Printf("HTTP GET error: %v", err)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	log.Printf("Response Status: %s", resp.Status)
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API returned non-OK status: %s", resp.Status)
 	}
 
 	var result MangaListResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("JSON decode error: %v", err)
+		return nil, fmt.Errorf("decoding manga list: %w", err)
+	}
+	return &result, nil
+}
+
+// listManga fetches a page of manga with the filter applied. Exclusions the
+// API cannot express are removed from the page afterwards, and the total is
+// reduced by the number of excluded manga matching the same query so that
+// page counts stay accurate. Counting them costs one more request per
+// maxIDsPerRequest excluded IDs, so excluded IDs should be kept to a few
+// batches; a failed count leaves the total as reported.
+func listManga(ctx context.Context, params url.Values, filter Filter) (*MangaListResponse, error) {
+	filter.apply(params)
+	result, err := GetMangaListPage(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	result.Data = filter.filterManga(result.Data)
+
+	if len(filter.ExcludedIDs) > 0 {
+		hidden, err := countMatching(ctx, params, filter.ExcludedIDs)
+		if err != nil {
+			slog.WarnContext(ctx, "Error counting excluded manga", "err", err)
+		} else {
+			result.Total = max(result.Total-hidden, 0)
+		}
+	}
+	return result, nil
+}
+
+// countMatching returns how many of the given manga IDs match the query.
+func countMatching(ctx context.Context, params url.Values, ids []string) (int, error) {
+	count := 0
+	for batch := range slices.Chunk(ids, maxIDsPerRequest) {
+		query := url.Values{}
+		for key, values := range params {
+			if key != "limit" && key != "offset" {
+				query[key] = values
+			}
+		}
+		query.Set("limit", "1")
+		for _, id := range batch {
+			query.Add("ids[]", id)
+		}
+		result, err := GetMangaListPage(ctx, query)
+		if err != nil {
+			return 0, err
+		}
+		count += result.Total
+	}
+	return count, nil
+}
+
+// listMangaData is listManga for callers that do not paginate.
+func listMangaData(ctx context.Context, params url.Values, filter Filter) ([]Manga, error) {
+	result, err := listManga(ctx, params, filter)
+	if err != nil {
 		return nil, err
 	}
 	return result.Data, nil
 }
 
 // SearchManga searches for manga by title.
-func SearchManga(title string) ([]Manga, error) {
+func SearchManga(ctx context.Context, title string, filter Filter) ([]Manga, error) {
 	params := url.Values{}
 	params.Add("title", title)
-	return GetMangaList(params)
+	return listMangaData(ctx, params, filter)
+}
+
+// SearchMangaWithPagination searches for manga by title with pagination.
+func SearchMangaWithPagination(ctx context.Context, title string, limit, offset int, filter Filter) (*MangaListResponse, error) {
+	params := url.Values{}
+	params.Add("title", title)
+	params.Add("limit", fmt.Sprintf("%d", limit))
+	params.Add("offset", fmt.Sprintf("%d", offset))
+	return listManga(ctx, params, filter)
 }
 
 // GetPopularManga fetches popular manga.
-func GetPopularManga() ([]Manga, error) {
+func GetPopularManga(ctx context.Context, filter Filter) ([]Manga, error) {
 	params := url.Values{}
 	params.Add("order[followedCount]", "desc")
 	params.Add("limit", "10") // Fetch top 10 popular manga
-	return GetMangaList(params)
+	return listMangaData(ctx, params, filter)
 }
 
 // GetPopularMangaWithPagination fetches popular manga with pagination.
-func GetPopularMangaWithPagination(limit, offset int) ([]Manga, error) {
+func GetPopularMangaWithPagination(ctx context.Context, limit, offset int, filter Filter) (*MangaListResponse, error) {
 	params := url.Values{}
 	params.Add("order[followedCount]", "desc")
 	params.Add("limit", fmt.Sprintf("%d", limit))
 	params.Add("offset", fmt.Sprintf("%d", offset))
-	return GetMangaList(params)
+	return listManga(ctx, params, filter)
 }
 
 // GetRecentlyUpdatedManga fetches recently updated manga.
-func GetRecentlyUpdatedManga() ([]Manga, error) {
+func GetRecentlyUpdatedManga(ctx context.Context, filter Filter) ([]Manga, error) {
 	params := url.Values{}
 	params.Add("order[updatedAt]", "desc")
 	params.Add("limit", "10") // Fetch 10 recently updated manga
-	return GetMangaList(params)
+	return listMangaData(ctx, params, filter)
+}
+
+// GetMangaByTag fetches the most followed manga carrying a tag, with pagination.
+func GetMangaByTag(ctx context.Context, tagID string, limit, offset int, filter Filter) (*MangaListResponse, error) {
+	params := url.Values{}
+	params.Add("includedTags[]", tagID)
+	params.Add("order[followedCount]", "desc")
+	params.Add("limit", fmt.Sprintf("%d", limit))
+	params.Add("offset", fmt.Sprintf("%d", offset))
+	return listManga(ctx, params, filter)
 }
 
 // GetRecentlyUpdatedMangaWithPagination fetches recently updated manga with pagination.
-func GetRecentlyUpdatedMangaWithPagination(limit, offset int) ([]Manga, error) {
+func GetRecentlyUpdatedMangaWithPagination(ctx context.Context, limit, offset int, filter Filter) (*MangaListResponse, error) {
 	params := url.Values{}
 	params.Add("order[updatedAt]", "desc")
 	params.Add("limit", fmt.Sprintf("%d", limit))
 	params.Add("offset", fmt.Sprintf("%d", offset))
-	return GetMangaList(params)
+	return listManga(ctx, params, filter)
 }
 
 // GetRandomManga fetches a random manga.
-func GetRandomManga() (Manga, error) {
-	requestURL := fmt.Sprintf("%s/manga/random", apiBase)
-	log.Printf("Requesting URL: %s", requestURL)
-	resp, err := httpClient.Get(requestURL)
+func GetRandomManga(ctx context.Context, filter Filter) (Manga, error) {
+	params := url.Values{}
+	filter.applyRandom(params)
+	requestURL := fmt.Sprintf("%s/manga/random?%s", apiBase, params.Encode())
+	resp, err := get(ctx, requestURL)
 	if err != nil {
-		log.Printf("HTTP GET error: %v", err)
 		return Manga{}, err
 	}
 	defer resp.Body.Close()
 
-	log.Printf("Response Status: %s", resp.Status)
 	if resp.StatusCode != http.StatusOK {
 		return Manga{}, fmt.Errorf("API returned non-OK status: %s", resp.Status)
 	}
@@ -204,8 +568,7 @@ func GetRandomManga() (Manga, error) {
 		Data Manga `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("JSON decode error: %v", err)
-		return Manga{}, err
+		return Manga{}, fmt.Errorf("decoding random manga: %w", err)
 	}
 
 	// Ensure Attributes is initialized, even if empty from API
@@ -216,65 +579,109 @@ func GetRandomManga() (Manga, error) {
 	return result.Data, nil
 }
 
-// GetRandomMangas fetches multiple random mangas.
-func GetRandomMangas(count int) ([]Manga, error) {
+// GetRandomMangas fetches up to count distinct random mangas, at most
+// MaxRandomMangas. Requests run with bounded concurrency; duplicates and
+// filtered results are topped up with further requests until the attempt
+// budget runs out. If fewer mangas than requested could be fetched, the ones
+// that were are returned together with a *PartialError.
+func GetRandomMangas(ctx context.Context, count int, filter Filter) ([]Manga, error) {
+	count = min(max(count, 1), MaxRandomMangas)
+
 	var mangas []Manga
+	var lastErr error
+	seen := make(map[string]bool)
+	attempts := 0
+	for len(mangas) < count && attempts < count*randomAttemptsPerManga {
+		need := count - len(mangas)
+		attempts += need
+		for _, res := range fetchRandomBatch(ctx, need, filter) {
+			switch {
+			case res.err != nil:
+				slog.WarnContext(ctx, "Error fetching random manga", "err", res.err)
+				lastErr = res.err
+			case seen[res.manga.ID]:
+				slog.DebugContext(ctx, "Dropping duplicate random manga", "manga_id", res.manga.ID)
+			case !filter.Allows(res.manga):
+				slog.DebugContext(ctx, "Dropping filtered random manga", "manga_id", res.manga.ID)
+			case len(mangas) < count:
+				seen[res.manga.ID] = true
+				mangas = append(mangas, res.manga)
+			}
+		}
+	}
+
+	if len(mangas) < count {
+		return mangas, &PartialError{Requested: count, Returned: len(mangas), Err: lastErr}
+	}
+	return mangas, nil
+}
+
+type randomResult struct {
+	manga Manga
+	err   error
+}
+
+// fetchRandomBatch performs n random manga requests, at most
+// randomConcurrency at a time.
+func fetchRandomBatch(ctx context.Context, n int, filter Filter) []randomResult {
+	results := make([]randomResult, n)
+	sem := make(chan struct{}, randomConcurrency)
 	var wg sync.WaitGroup
-	var mu sync.Mutex // Mutex to protect shared 'mangas' slice
-	
-	for i := 0; i < count; i++ {
+	for i := range results {
 		wg.Add(1)
-		go func() {
+		sem <- struct{}{}
+		go func(i int) {
 			defer wg.Done()
-			manga, err := GetRandomManga()
-			if err != nil {
-				log.Printf("Error fetching random manga: %v", err)
-				return
-			}
-			mu.Lock()
-			mangas = append(mangas, manga)
-			mu.Unlock()
-		}()
+			defer func() { <-sem }()
+			results[i].manga, results[i].err = GetRandomManga(ctx, filter)
+		}(i)
 	}
 	wg.Wait()
+	return results
+}
 
-	// Fetch covers for random mangas
-	var coverWg sync.WaitGroup
-	for i := range mangas {
-		coverWg.Add(1)
-		go func(i int) {
-			defer coverWg.Done()
-			// Only attempt to fetch cover if Attributes exists and has a Title (as a proxy for valid attributes)
-			if mangas[i].Attributes.Title != nil { // Check for a non-nil attribute, like Title
-				coverURL, coverErr := GetCoverForManga(mangas[i].ID)
-				if coverErr != nil {
-					log.Printf("Error fetching cover for random manga %s: %v", mangas[i].ID, coverErr)
-				} else {
-					mangas[i].Attributes.CoverURL = coverURL
-				}
-			} else {
-				log.Printf("Skipping cover fetch for manga %s: Attributes or Title is nil", mangas[i].ID)
-			}
-		}(i)
+// PartialError reports that fewer random mangas than requested could be
+// fetched. Err is the last upstream error, if any; a nil Err means the
+// attempts were used up by duplicates or filtered results.
+type PartialError struct {
+	Requested int
+	Returned  int
+	Err       error
+}
+
+func (e *PartialError) Error() string {
+	msg := fmt.Sprintf("fetched %d of %d random mangas", e.Returned, e.Requested)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
 	}
-	coverWg.Wait()
+	return msg
+}
 
-	return mangas, nil
+func (e *PartialError) Unwrap() error {
+	return e.Err
 }
 
 // GetManga fetches a specific manga by its ID.
-func GetManga(mangaID string) (Manga, error) {
-	if manga, ok := mangaCache.Get(mangaID); ok {
+func GetManga(ctx context.Context, mangaID string) (Manga, error) {
+	if manga, ok := mangaCache.Get(ctx, mangaID); ok {
 		return manga, nil
 	}
 
-	url := fmt.Sprintf("%s/manga/%s", apiBase, mangaID)
-	resp, err := httpClient.Get(url)
+	params := url.Values{}
+	params.Add("includes[]", "author")
+	params.Add("includes[]", "artist")
+	params.Add("includes[]", "manga")
+	requestURL := fmt.Sprintf("%s/manga/%s?%s", apiBase, mangaID, params.Encode())
+	resp, err := get(ctx, requestURL)
 	if err != nil {
 		return Manga{}, err
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(resp, "manga "+mangaID); err != nil {
+		return Manga{}, err
+	}
+
 	var result struct {
 		Data Manga `json:"data"`
 	}
@@ -291,28 +698,47 @@ func GetManga(mangaID string) (Manga, error) {
 	return result.Data, nil
 }
 
-// GetChapterDetails fetches a specific chapter by its ID.
-func GetChapterDetails(chapterID string) (Chapter, error) {
-	url := fmt.Sprintf("%s/chapter/%s", apiBase, chapterID)
-	resp, err := httpClient.Get(url)
+// GetChapterDetails fetches a specific chapter by its ID, expanding the
+// scanlation groups, uploader and manga it relates to.
+func GetChapterDetails(ctx context.Context, chapterID string) (Chapter, error) {
+	if chapter, ok := chapterDetailsCache.Get(ctx, chapterID); ok {
+		return chapter, nil
+	}
+	params := url.Values{}
+	params.Add("includes[]", "scanlation_group")
+	params.Add("includes[]", "user")
+	params.Add("includes[]", "manga")
+	requestURL := fmt.Sprintf("%s/chapter/%s?%s", apiBase, chapterID, params.Encode())
+	resp, err := get(ctx, requestURL)
 	if err != nil {
 		return Chapter{}, err
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(resp, "chapter "+chapterID); err != nil {
+		return Chapter{}, err
+	}
+
 	var result struct {
 		Data Chapter `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return Chapter{}, err
 	}
+	if result.Data.ID != "" {
+		chapterDetailsCache.Set(chapterID, result.Data)
+	}
 	return result.Data, nil
 }
 
 // GetChapterPages fetches the pages for a specific chapter by its ID.
-func GetChapterPages(chapterID string) ([]string, error) {
+// Readers that request pages one at a time share one lookup for a while.
+func GetChapterPages(ctx context.Context, chapterID string) ([]string, error) {
+	if pages, ok := pagesCache.Get(ctx, chapterID); ok {
+		return pages, nil
+	}
 	url := fmt.Sprintf("%s/at-home/server/%s", apiBase, chapterID)
-	resp, err := httpClient.Get(url)
+	resp, err := get(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -327,16 +753,17 @@ func GetChapterPages(chapterID string) ([]string, error) {
 	for _, page := range result.Chapter.Data {
 		pages = append(pages, fmt.Sprintf("%s/data/%s/%s", result.BaseURL, result.Chapter.Hash, page))
 	}
+	pagesCache.Set(chapterID, pages)
 
 	return pages, nil
 }
 
 // GetCoverForManga fetches the cover data for a specific manga ID and returns the cover URL.
 // It calls the cover endpoint using a filter for the manga ID.
-func GetCoverForManga(mangaID string) (string, error) {
+func GetCoverForManga(ctx context.Context, mangaID string) (string, error) {
 	// The API supports filtering by manga id: ?manga[]=<mangaID>
 	url := fmt.Sprintf("%s/cover?manga[]=%s", apiBase, mangaID)
-	resp, err := httpClient.Get(url)
+	resp, err := get(ctx, url)
 	if err != nil {
 		return "", err
 	}
@@ -358,9 +785,9 @@ func GetCoverForManga(mangaID string) (string, error) {
 }
 
 // GetMangaChapters fetches all chapters for a specific manga ID.
-func GetMangaChapters(mangaID string, limit, offset int) (*ChaptersResponse, error) {
+func GetMangaChapters(ctx context.Context, mangaID string, limit, offset int) (*ChaptersResponse, error) {
 	cacheKey := fmt.Sprintf("%s-%d-%d", mangaID, limit, offset)
-	if chapters, ok := chapterCache.Get(cacheKey); ok {
+	if chapters, ok := chapterCache.Get(ctx, cacheKey); ok {
 		return chapters, nil
 	}
 
@@ -368,11 +795,14 @@ func GetMangaChapters(mangaID string, limit, offset int) (*ChaptersResponse, err
 	params := url.Values{}
 	params.Add("limit", fmt.Sprintf("%d", limit))
 	params.Add("offset", fmt.Sprintf("%d", offset))
-	params.Add("translatedLanguage[]", "en")
+	for _, lang := range chapterLanguages {
+		params.Add("translatedLanguage[]", lang)
+	}
 	params.Add("order[chapter]", "asc")
+	params.Add("includes[]", "scanlation_group")
 	baseURL.RawQuery = params.Encode()
 
-	resp, err := httpClient.Get(baseURL.String())
+	resp, err := get(ctx, baseURL.String())
 	if err != nil {
 		return nil, err
 	}
@@ -388,6 +818,6 @@ func GetMangaChapters(mangaID string, limit, offset int) (*ChaptersResponse, err
 }
 
 // GetChaptersForManga fetches chapters for a specific manga ID.
-func GetChaptersForManga(mangaID string, limit, offset int) (*ChaptersResponse, error) {
-	return GetMangaChapters(mangaID, limit, offset)
-}
\ No newline at end of file
+func GetChaptersForManga(ctx context.Context, mangaID string, limit, offset int) (*ChaptersResponse, error) {
+	return GetMangaChapters(ctx, mangaID, limit, offset)
+}
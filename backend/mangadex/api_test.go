@@ -0,0 +1,58 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// TestChapterPageByPage checks that a reader fetching a chapter one page at
+// a time looks up the chapter and its at-home server once, and again only
+// after the page URLs have expired.
+func TestChapterPageByPage(t *testing.T) {
+	var details, atHome atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /chapter/{id}", func(w http.ResponseWriter, r *http.Request) {
+		details.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"result": "ok", "data": map[string]any{"id": r.PathValue("id"), "type": "chapter"}})
+	})
+	mux.HandleFunc("GET /at-home/server/{id}", func(w http.ResponseWriter, r *http.Request) {
+		atHome.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"baseUrl": "https://uploads.example.com",
+			"chapter": map[string]any{"hash": "h", "data": []string{"1.png", "2.png", "3.png"}},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	Configure(Options{APIBase: srv.URL})
+
+	ctx := context.Background()
+	readPages := func() {
+		t.Helper()
+		for range 3 {
+			if chapter, err := GetChapterDetails(ctx, "c1"); err != nil || chapter.ID != "c1" {
+				t.Fatalf("got chapter %q, err %v", chapter.ID, err)
+			}
+			if pages, err := GetChapterPages(ctx, "c1"); err != nil || len(pages) != 3 {
+				t.Fatalf("got pages %v, err %v", pages, err)
+			}
+		}
+	}
+	readPages()
+	if d, a := details.Load(), atHome.Load(); d != 1 || a != 1 {
+		t.Fatalf("%d chapter and %d at-home requests for three pages, want 1 each", d, a)
+	}
+
+	if ttl := expire(chapterDetailsCache, "c1"); ttl <= 0 || ttl > pagesTTL {
+		t.Errorf("chapter cached for %v, want at most %v", ttl, pagesTTL)
+	}
+	expire(pagesCache, "c1")
+	readPages()
+	if d, a := details.Load(), atHome.Load(); d != 2 || a != 2 {
+		t.Errorf("%d chapter and %d at-home requests after expiry, want 2 each", d, a)
+	}
+}
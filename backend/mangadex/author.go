@@ -0,0 +1,84 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// LocalizedString is a map of language code to text. The API encodes an
+// empty map as an empty JSON array, which this type decodes as nil.
+type LocalizedString map[string]string
+
+// UnmarshalJSON accepts both the object form and the empty-array form.
+func (l *LocalizedString) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '[' {
+		*l = nil
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*l = m
+	return nil
+}
+
+// Author represents an author or artist from the API.
+type Author struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Name      string          `json:"name"`
+		ImageURL  string          `json:"imageUrl"`
+		Biography LocalizedString `json:"biography"`
+		Twitter   string          `json:"twitter"`
+		Pixiv     string          `json:"pixiv"`
+		Website   string          `json:"website"`
+	} `json:"attributes"`
+}
+
+// Bio returns the English biography if available, otherwise any other one.
+func (a Author) Bio() string {
+	if bio := a.Attributes.Biography["en"]; bio != "" {
+		return bio
+	}
+	for _, bio := range a.Attributes.Biography {
+		if bio != "" {
+			return bio
+		}
+	}
+	return ""
+}
+
+// GetAuthor fetches a specific author or artist by ID.
+func GetAuthor(ctx context.Context, authorID string) (Author, error) {
+	requestURL := fmt.Sprintf("%s/author/%s", apiBase, authorID)
+	resp, err := get(ctx, requestURL)
+	if err != nil {
+		return Author{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp, "author "+authorID); err != nil {
+		return Author{}, err
+	}
+
+	var result struct {
+		Data Author `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Author{}, err
+	}
+	return result.Data, nil
+}
+
+// GetAuthorManga fetches the works an author wrote or drew, most followed first.
+func GetAuthorManga(ctx context.Context, authorID string, limit, offset int, filter Filter) (*MangaListResponse, error) {
+	params := url.Values{}
+	params.Add("authorOrArtist", authorID)
+	params.Add("order[followedCount]", "desc")
+	params.Add("limit", fmt.Sprintf("%d", limit))
+	params.Add("offset", fmt.Sprintf("%d", offset))
+	return listManga(ctx, params, filter)
+}
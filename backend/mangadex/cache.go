@@ -1,31 +1,106 @@
 package mangadex
 
 import (
+	"context"
 	"sync"
+	"time"
+
+	"github.com/nithish-95/manga/backend/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Cache is a simple in-memory cache with a mutex for concurrent access.
+// Entries optionally expire after a fixed TTL, and the number of entries
+// can be bounded.
 
 type Cache[T any] struct {
-	data map[string]T
-	mu   sync.RWMutex
+	name       string // label for the cache metrics
+	data       map[string]cacheEntry[T]
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.RWMutex
+}
+
+type cacheEntry[T any] struct {
+	value   T
+	expires time.Time // zero means the entry never expires
+}
+
+// NewCache returns a cache whose entries never expire. The name labels its
+// metrics.
+func NewCache[T any](name string) *Cache[T] {
+	return NewTTLCache[T](name, 0)
 }
 
-func NewCache[T any]() *Cache[T] {
+// NewTTLCache returns a cache whose entries expire ttl after being set.
+// A ttl of zero keeps entries forever.
+func NewTTLCache[T any](name string, ttl time.Duration) *Cache[T] {
 	return &Cache[T]{
-		data: make(map[string]T),
+		name: name,
+		data: make(map[string]cacheEntry[T]),
+		ttl:  ttl,
 	}
 }
 
-func (c *Cache[T]) Get(key string) (T, bool) {
+// configure changes the TTL of entries set from now on and the maximum
+// number of entries. A maxEntries of zero leaves the cache unbounded.
+func (c *Cache[T]) configure(ttl time.Duration, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	c.maxEntries = maxEntries
+}
+
+// Get returns the live entry for key. The lookup is traced as a span of
+// the request in ctx.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, bool) {
+	_, span := tracer.Start(ctx, "cache.get "+c.name, trace.WithAttributes(attribute.String("cache.name", c.name)))
+	defer span.End()
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	val, ok := c.data[key]
-	return val, ok
+	entry, ok := c.data[key]
+	if !ok || (!entry.expires.IsZero() && time.Now().After(entry.expires)) {
+		metrics.CacheMisses.WithLabelValues(c.name).Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", false))
+		var zero T
+		return zero, false
+	}
+	metrics.CacheHits.WithLabelValues(c.name).Inc()
+	span.SetAttributes(attribute.Bool("cache.hit", true))
+	return entry.value, true
 }
 
 func (c *Cache[T]) Set(key string, value T) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.data[key] = value
+	if _, exists := c.data[key]; !exists && c.maxEntries > 0 && len(c.data) >= c.maxEntries {
+		c.evict()
+	}
+	entry := cacheEntry[T]{value: value}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	c.data[key] = entry
+}
+
+// evict makes room for one entry, dropping expired entries if there are any
+// and an arbitrary one otherwise. The caller must hold the write lock.
+func (c *Cache[T]) evict() {
+	now := time.Now()
+	for key, entry := range c.data {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(c.data, key)
+			metrics.CacheEvictions.WithLabelValues(c.name).Inc()
+		}
+	}
+	if len(c.data) < c.maxEntries {
+		return
+	}
+	for key := range c.data {
+		delete(c.data, key)
+		metrics.CacheEvictions.WithLabelValues(c.name).Inc()
+		return
+	}
 }
@@ -0,0 +1,247 @@
+package mangadex
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nithish-95/manga/backend/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options configures the package. Zero fields keep the defaults.
+type Options struct {
+	APIBase   string   // base URL of the MangaDex API
+	CoverBase string   // base URL cover images are served from
+	Languages []string // translated languages chapter lists are fetched in
+
+	CacheSize     int           // maximum entries per cache, 0 for no limit
+	CacheTTL      time.Duration // lifetime of cached manga, chapters and tags, 0 for ever
+	StatisticsTTL time.Duration // lifetime of cached statistics
+
+	RateLimit float64 // API requests per second, 0 for no limit
+	RateBurst int     // requests allowed at once before RateLimit applies
+}
+
+// chapterLanguages are the translated languages chapter lists are fetched in.
+var chapterLanguages = []string{"en"}
+
+// Configure applies opts. It must be called before any other function in
+// the package is used.
+func Configure(opts Options) {
+	if opts.APIBase != "" {
+		apiBase = opts.APIBase
+	}
+	if opts.CoverBase != "" {
+		coverBaseURL = opts.CoverBase
+	}
+	if len(opts.Languages) > 0 {
+		chapterLanguages = opts.Languages
+	}
+
+	mangaCache.configure(opts.CacheTTL, opts.CacheSize)
+	chapterCache.configure(opts.CacheTTL, opts.CacheSize)
+	tagCache.configure(opts.CacheTTL, opts.CacheSize)
+	statisticsTTL := opts.StatisticsTTL
+	if statisticsTTL == 0 {
+		statisticsTTL = defaultStatisticsTTL
+	}
+	statisticsCache.configure(statisticsTTL, opts.CacheSize)
+	pagesCache.configure(pagesTTL, opts.CacheSize)
+	chapterDetailsCache.configure(pagesTTL, opts.CacheSize)
+	feedCache.configure(feedTTL, opts.CacheSize)
+	volumeCache.configure(volumeTTL, opts.CacheSize)
+
+	// Waiting for the limiter is measured separately, so it stays outside
+	// the instrumented transport.
+	var transport http.RoundTripper = &instrumentedTransport{next: http.DefaultTransport}
+	if opts.RateLimit > 0 {
+		burst := max(opts.RateBurst, 1)
+		transport = &rateLimitedTransport{
+			limiter: &rateLimiter{rate: opts.RateLimit, burst: burst, tokens: float64(burst), last: time.Now()},
+			next:    transport,
+		}
+	}
+	httpClient.Transport = &retryTransport{next: transport}
+}
+
+const (
+	// upstreamRetries is how many times a request that failed transiently
+	// is sent again; upstreamRetryDelay is the wait before the first resend,
+	// doubling for each further one.
+	upstreamRetries    = 2
+	upstreamRetryDelay = 250 * time.Millisecond
+)
+
+// resendKey is the context key under which retryTransport stores how many
+// times a request has been sent before.
+type resendKey struct{}
+
+// retryTransport resends GET requests that failed with a network error or
+// an overloaded or unavailable answer. Every attempt goes through the rate
+// limiter again and is traced as its own client span.
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := upstreamRetryDelay
+	for resends := 0; ; resends++ {
+		attempt := req
+		if resends > 0 {
+			attempt = req.WithContext(context.WithValue(req.Context(), resendKey{}, resends))
+		}
+		resp, err := t.next.RoundTrip(attempt)
+		if resends == upstreamRetries || req.Method != http.MethodGet || !transient(req.Context(), resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		delay *= 2
+	}
+}
+
+// transient reports whether a failed attempt is worth repeating.
+func transient(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// rateLimiter is a token bucket. Callers that find it empty take a token on
+// credit and sleep until it would have been refilled, so requests queue in
+// arrival order.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	metrics.RateLimitWaits.Observe(delay.Seconds())
+	trace.SpanFromContext(ctx).AddEvent("rate limited", trace.WithAttributes(attribute.Int64("wait_ms", delay.Milliseconds())))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedTransport waits for the limiter before every request.
+type rateLimitedTransport struct {
+	limiter *rateLimiter
+	next    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// tracer creates the spans of upstream calls and cache lookups.
+var tracer = otel.Tracer("github.com/nithish-95/manga/backend/mangadex")
+
+// instrumentedTransport records the count, status and latency of every
+// request by endpoint, and traces it as a client span. Resent requests
+// carry their retry count in http.request.resend_count.
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointLabel(req.URL.Path)
+	ctx, span := tracer.Start(req.Context(), req.Method+" "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.template", endpoint),
+			attribute.String("url.full", req.URL.String()),
+			attribute.String("server.address", req.URL.Host),
+		),
+	)
+	defer span.End()
+	if resends, ok := req.Context().Value(resendKey{}).(int); ok {
+		span.SetAttributes(attribute.Int("http.request.resend_count", resends))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	metrics.UpstreamDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	} else {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	metrics.UpstreamRequests.WithLabelValues(endpoint, code).Inc()
+	return resp, err
+}
+
+// entityCollections are the path segments followed by an entity ID, and
+// namedEndpoints the segments in those positions that are not IDs.
+var (
+	entityCollections = map[string]bool{"manga": true, "chapter": true, "author": true, "group": true, "cover": true, "server": true}
+	namedEndpoints    = map[string]bool{"random": true, "tag": true}
+)
+
+// endpointLabel turns a request path into a metric label by replacing
+// entity IDs, so /manga/<id>/feed becomes /manga/{id}/feed. Anything in an
+// ID position is replaced, so malformed IDs cannot create new series.
+func endpointLabel(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		if entityCollections[segments[i-1]] && segments[i] != "" && !namedEndpoints[segments[i]] {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
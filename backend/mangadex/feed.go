@@ -0,0 +1,148 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// feedPageSize is the largest page the chapter feed returns.
+const feedPageSize = 100
+
+// feedTimeFormat is the timestamp format of publishAtSince and similar
+// query parameters, which take no time zone and are read as UTC.
+const feedTimeFormat = "2006-01-02T15:04:05"
+
+// feedTTL is how long the latest chapters of a manga are reused. It is
+// short and independent of the configured cache TTL, so that feeds show
+// new chapters soon after they are published.
+const feedTTL = 5 * time.Minute
+
+var feedCache = NewTTLCache[*ChaptersResponse]("feeds", feedTTL)
+
+// volumeTTL is how long the chapter list of a volume is reused. Volumes
+// still being published gain chapters, so it is bounded like feedTTL.
+const volumeTTL = 15 * time.Minute
+
+var volumeCache = NewTTLCache[[]ChapterData]("volumes", volumeTTL)
+
+// GetChaptersSince fetches the chapters of a manga published after since,
+// oldest first, in the configured languages. It is not cached: it is meant
+// for polling.
+func GetChaptersSince(ctx context.Context, mangaID string, since time.Time) ([]ChapterData, error) {
+	var chapters []ChapterData
+	for offset := 0; ; offset += feedPageSize {
+		params := url.Values{}
+		params.Set("limit", strconv.Itoa(feedPageSize))
+		params.Set("offset", strconv.Itoa(offset))
+		params.Set("publishAtSince", since.UTC().Format(feedTimeFormat))
+		params.Set("order[publishAt]", "asc")
+		for _, lang := range chapterLanguages {
+			params.Add("translatedLanguage[]", lang)
+		}
+		params.Add("includes[]", "scanlation_group")
+		params.Add("includes[]", "manga")
+		requestURL := fmt.Sprintf("%s/manga/%s/feed?%s", apiBase, mangaID, params.Encode())
+
+		resp, err := get(ctx, requestURL)
+		if err != nil {
+			return nil, err
+		}
+		var page ChaptersResponse
+		err = checkStatus(resp, "feed of manga "+mangaID)
+		if err == nil {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		chapters = append(chapters, page.Data...)
+		if len(page.Data) == 0 || offset+len(page.Data) >= page.Total {
+			return chapters, nil
+		}
+	}
+}
+
+// GetLatestChapters fetches the most recently published chapters of a
+// manga, newest first, in the configured languages.
+func GetLatestChapters(ctx context.Context, mangaID string, limit int) (*ChaptersResponse, error) {
+	cacheKey := fmt.Sprintf("latest-%s-%d", mangaID, limit)
+	if chapters, ok := feedCache.Get(ctx, cacheKey); ok {
+		return chapters, nil
+	}
+
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("order[publishAt]", "desc")
+	for _, lang := range chapterLanguages {
+		params.Add("translatedLanguage[]", lang)
+	}
+	params.Add("includes[]", "scanlation_group")
+	requestURL := fmt.Sprintf("%s/manga/%s/feed?%s", apiBase, mangaID, params.Encode())
+
+	resp, err := get(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp, "feed of manga "+mangaID); err != nil {
+		return nil, err
+	}
+	var chapters ChaptersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chapters); err != nil {
+		return nil, err
+	}
+
+	feedCache.Set(cacheKey, &chapters)
+	return &chapters, nil
+}
+
+// GetVolumeChapters fetches the chapters of one volume of a manga, ordered
+// by chapter number, in the configured languages.
+func GetVolumeChapters(ctx context.Context, mangaID, volume string) ([]ChapterData, error) {
+	cacheKey := fmt.Sprintf("volume-%s-%s", mangaID, volume)
+	if chapters, ok := volumeCache.Get(ctx, cacheKey); ok {
+		return chapters, nil
+	}
+
+	var chapters []ChapterData
+	for offset := 0; ; offset += feedPageSize {
+		params := url.Values{}
+		params.Set("manga", mangaID)
+		params.Add("volume[]", volume)
+		params.Set("limit", strconv.Itoa(feedPageSize))
+		params.Set("offset", strconv.Itoa(offset))
+		params.Set("order[chapter]", "asc")
+		for _, lang := range chapterLanguages {
+			params.Add("translatedLanguage[]", lang)
+		}
+		params.Add("includes[]", "scanlation_group")
+		requestURL := fmt.Sprintf("%s/chapter?%s", apiBase, params.Encode())
+
+		resp, err := get(ctx, requestURL)
+		if err != nil {
+			return nil, err
+		}
+		var page ChaptersResponse
+		err = checkStatus(resp, "volume "+volume+" of manga "+mangaID)
+		if err == nil {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		chapters = append(chapters, page.Data...)
+		if len(page.Data) == 0 || offset+len(page.Data) >= page.Total {
+			break
+		}
+	}
+
+	volumeCache.Set(cacheKey, chapters)
+	return chapters, nil
+}
@@ -0,0 +1,90 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// chapterServer answers every request with one chapter and counts the
+// requests. The mangadex package is pointed at it with no cache TTL, so
+// that only caches with their own TTL expire.
+func chapterServer(t *testing.T) *atomic.Int64 {
+	t.Helper()
+	requests := new(atomic.Int64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"result": "ok", "data": []any{map[string]any{"id": "c1"}}, "total": 1})
+	}))
+	t.Cleanup(srv.Close)
+	Configure(Options{APIBase: srv.URL})
+	return requests
+}
+
+// expire makes the entry for key expire now and returns how long it had
+// left.
+func expire[T any](c *Cache[T], key string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.data[key]
+	left := time.Until(entry.expires)
+	entry.expires = time.Now().Add(-time.Second)
+	c.data[key] = entry
+	return left
+}
+
+// TestLatestChaptersExpire checks that feeds are cached briefly even when
+// other caches keep their entries forever.
+func TestLatestChaptersExpire(t *testing.T) {
+	requests := chapterServer(t)
+	fetch := func() {
+		t.Helper()
+		chapters, err := GetLatestChapters(context.Background(), "feed-manga", 10)
+		if err != nil || len(chapters.Data) != 1 {
+			t.Fatalf("got %v, err %v; want one chapter", chapters, err)
+		}
+	}
+	fetch()
+	fetch()
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d requests for two fetches, want 1", n)
+	}
+
+	if ttl := expire(feedCache, "latest-feed-manga-10"); ttl <= 0 || ttl > feedTTL {
+		t.Errorf("feed cached for %v, want at most %v", ttl, feedTTL)
+	}
+	fetch()
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d requests after the feed expired, want 2", n)
+	}
+}
+
+// TestVolumeChaptersExpire checks that volume chapter lists are cached
+// briefly, so that chapters added to a volume are picked up.
+func TestVolumeChaptersExpire(t *testing.T) {
+	requests := chapterServer(t)
+	fetch := func() {
+		t.Helper()
+		chapters, err := GetVolumeChapters(context.Background(), "volume-manga", "1")
+		if err != nil || len(chapters) != 1 {
+			t.Fatalf("got %v, err %v; want one chapter", chapters, err)
+		}
+	}
+	fetch()
+	fetch()
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d requests for two fetches, want 1", n)
+	}
+
+	if ttl := expire(volumeCache, "volume-volume-manga-1"); ttl <= 0 || ttl > volumeTTL {
+		t.Errorf("volume cached for %v, want at most %v", ttl, volumeTTL)
+	}
+	fetch()
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d requests after the volume expired, want 2", n)
+	}
+}
@@ -0,0 +1,132 @@
+package mangadex
+
+import (
+	"net/url"
+	"slices"
+)
+
+// Content ratings understood by the API, from least to most explicit.
+const (
+	RatingSafe         = "safe"
+	RatingSuggestive   = "suggestive"
+	RatingErotica      = "erotica"
+	RatingPornographic = "pornographic"
+)
+
+// ContentRatings lists every content rating in order of explicitness.
+var ContentRatings = []string{RatingSafe, RatingSuggestive, RatingErotica, RatingPornographic}
+
+// Filter restricts what list, search and random calls may return. The zero
+// value leaves the API defaults in place.
+//
+// Endpoints translate as much of the filter as they can into query
+// parameters; whatever an endpoint cannot express is applied to the results
+// afterwards with Allows.
+type Filter struct {
+	ContentRatings    []string
+	IncludedTags      []string // tag IDs a manga must all carry
+	ExcludedTags      []string // tag IDs
+	ExcludedLanguages []string // original language codes
+	ExcludedIDs       []string // manga IDs, never expressible upstream
+}
+
+// apply adds the filter's query parameters to a /manga request.
+func (f Filter) apply(params url.Values) {
+	f.applyRandom(params)
+	for _, lang := range f.ExcludedLanguages {
+		params.Add("excludedOriginalLanguage[]", lang)
+	}
+}
+
+// applyRandom adds the query parameters /manga/random understands, which
+// exclude original languages.
+func (f Filter) applyRandom(params url.Values) {
+	for _, rating := range f.ContentRatings {
+		params.Add("contentRating[]", rating)
+	}
+	for _, tag := range f.IncludedTags {
+		params.Add("includedTags[]", tag)
+	}
+	for _, tag := range f.ExcludedTags {
+		params.Add("excludedTags[]", tag)
+	}
+}
+
+// applyChapters adds the query parameters /chapter understands, which
+// exclude tags.
+func (f Filter) applyChapters(params url.Values) {
+	for _, rating := range f.ContentRatings {
+		params.Add("contentRating[]", rating)
+	}
+	for _, lang := range f.ExcludedLanguages {
+		params.Add("excludedOriginalLanguage[]", lang)
+	}
+}
+
+// AllowsRating reports whether a manga with the given content rating passes
+// the filter. Manga without a rating are treated as safe.
+func (f Filter) AllowsRating(rating string) bool {
+	if len(f.ContentRatings) == 0 {
+		return true
+	}
+	if rating == "" {
+		rating = RatingSafe
+	}
+	return slices.Contains(f.ContentRatings, rating)
+}
+
+// Allows reports whether a manga passes every part of the filter.
+func (f Filter) Allows(m Manga) bool {
+	if !f.AllowsRating(m.Attributes.ContentRating) {
+		return false
+	}
+	if slices.Contains(f.ExcludedIDs, m.ID) {
+		return false
+	}
+	if m.Attributes.OriginalLanguage != "" && slices.Contains(f.ExcludedLanguages, m.Attributes.OriginalLanguage) {
+		return false
+	}
+	for _, tag := range m.Attributes.Tags {
+		if slices.Contains(f.ExcludedTags, tag.ID) {
+			return false
+		}
+	}
+	for _, included := range f.IncludedTags {
+		if !slices.ContainsFunc(m.Attributes.Tags, func(t Tag) bool { return t.ID == included }) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterManga returns the manga that pass the filter.
+func (f Filter) filterManga(mangas []Manga) []Manga {
+	var allowed []Manga
+	for _, m := range mangas {
+		if f.Allows(m) {
+			allowed = append(allowed, m)
+		}
+	}
+	return allowed
+}
+
+// Merge combines two filters; the result excludes everything either one
+// excludes. Content ratings are taken from f.
+func (f Filter) Merge(other Filter) Filter {
+	merged := Filter{ContentRatings: f.ContentRatings}
+	merged.IncludedTags = mergeUnique(f.IncludedTags, other.IncludedTags)
+	merged.ExcludedTags = mergeUnique(f.ExcludedTags, other.ExcludedTags)
+	merged.ExcludedLanguages = mergeUnique(f.ExcludedLanguages, other.ExcludedLanguages)
+	merged.ExcludedIDs = mergeUnique(f.ExcludedIDs, other.ExcludedIDs)
+	return merged
+}
+
+func mergeUnique(a, b []string) []string {
+	var out []string
+	for _, v := range append(slices.Clone(a), b...) {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
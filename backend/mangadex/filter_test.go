@@ -0,0 +1,75 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+// TestListMangaPostFilters checks that listings drop the results the API
+// could not exclude itself and take hidden manga off the total.
+func TestListMangaPostFilters(t *testing.T) {
+	manga := func(id, rating, lang, tag string) map[string]any {
+		return map[string]any{"id": id, "attributes": map[string]any{
+			"title":            map[string]string{"en": id},
+			"contentRating":    rating,
+			"originalLanguage": lang,
+			"tags":             []any{map[string]any{"id": tag}},
+		}}
+	}
+	var listQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if ids := q["ids[]"]; len(ids) > 0 {
+			// Counting hidden manga: only "hidden" matches the query.
+			json.NewEncoder(w).Encode(map[string]any{"data": []any{}, "total": len(slices.DeleteFunc(ids, func(id string) bool { return id != "hidden" }))})
+			return
+		}
+		listQuery = q
+		json.NewEncoder(w).Encode(map[string]any{"data": []any{
+			manga("kept", RatingSafe, "ja", "t-ok"),
+			manga("hidden", RatingSafe, "ja", "t-ok"),
+			manga("french", RatingSafe, "fr", "t-ok"),
+			manga("tagged", RatingSafe, "ja", "t-bad"),
+			manga("racy", RatingSuggestive, "ja", "t-ok"),
+		}, "total": 40})
+	}))
+	defer srv.Close()
+	Configure(Options{APIBase: srv.URL})
+
+	filter := Filter{
+		ContentRatings:    []string{RatingSafe},
+		ExcludedTags:      []string{"t-bad"},
+		ExcludedLanguages: []string{"fr"},
+		ExcludedIDs:       []string{"hidden", "elsewhere"},
+	}
+	result, err := GetPopularMangaWithPagination(context.Background(), 10, 20, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string][]string{
+		"contentRating[]":            {RatingSafe},
+		"excludedTags[]":             {"t-bad"},
+		"excludedOriginalLanguage[]": {"fr"},
+		"offset":                     {"20"},
+	} {
+		if got := listQuery[key]; !slices.Equal(got, want) {
+			t.Errorf("query %s = %v, want %v", key, got, want)
+		}
+	}
+	var ids []string
+	for _, m := range result.Data {
+		ids = append(ids, m.ID)
+	}
+	if !slices.Equal(ids, []string{"kept"}) {
+		t.Errorf("got %v, want [kept]", ids)
+	}
+	if result.Total != 39 {
+		t.Errorf("total = %d, want 39 (one hidden manga matches)", result.Total)
+	}
+}
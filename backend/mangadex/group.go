@@ -0,0 +1,72 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Group represents a scanlation group from the API.
+type Group struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Website     string `json:"website"`
+		Discord     string `json:"discord"`
+		Official    bool   `json:"official"`
+	} `json:"attributes"`
+}
+
+// GetGroup fetches a specific scanlation group by ID.
+func GetGroup(ctx context.Context, groupID string) (Group, error) {
+	requestURL := fmt.Sprintf("%s/group/%s", apiBase, groupID)
+	resp, err := get(ctx, requestURL)
+	if err != nil {
+		return Group{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp, "group "+groupID); err != nil {
+		return Group{}, err
+	}
+
+	var result struct {
+		Data Group `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Group{}, err
+	}
+	return result.Data, nil
+}
+
+// GetGroupChapters fetches the latest chapters released by a scanlation
+// group, with the manga each chapter belongs to expanded.
+func GetGroupChapters(ctx context.Context, groupID string, limit, offset int, filter Filter) (*ChaptersResponse, error) {
+	params := url.Values{}
+	params.Add("groups[]", groupID)
+	params.Add("limit", fmt.Sprintf("%d", limit))
+	params.Add("offset", fmt.Sprintf("%d", offset))
+	params.Add("order[readableAt]", "desc")
+	params.Add("includes[]", "manga")
+	filter.applyChapters(params)
+	requestURL := fmt.Sprintf("%s/chapter?%s", apiBase, params.Encode())
+
+	resp, err := get(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned non-OK status: %s", resp.Status)
+	}
+
+	var chaptersResp ChaptersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chaptersResp); err != nil {
+		return nil, err
+	}
+	return &chaptersResp, nil
+}
@@ -0,0 +1,122 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// defaultStatisticsTTL is how long statistics are cached unless configured
+// otherwise.
+const defaultStatisticsTTL = 5 * time.Minute
+
+// statisticsCache keeps per-manga statistics briefly; ratings and follow
+// counts change constantly, so they must not be cached forever.
+var statisticsCache = NewTTLCache[Statistics]("statistics", defaultStatisticsTTL)
+
+// Statistics holds the rating, follow and comment counts for a manga.
+type Statistics struct {
+	Rating struct {
+		Average      float64        `json:"average"`
+		Bayesian     float64        `json:"bayesian"`
+		Distribution map[string]int `json:"distribution"`
+	} `json:"rating"`
+	Follows  int `json:"follows"`
+	Comments *struct {
+		ThreadID     int `json:"threadId"`
+		RepliesCount int `json:"repliesCount"`
+	} `json:"comments"`
+}
+
+// RatingBucket is one score of a rating distribution.
+type RatingBucket struct {
+	Score   int
+	Votes   int
+	Percent int // share of all votes, rounded down
+}
+
+// Votes returns the total number of ratings in the distribution.
+func (s Statistics) Votes() int {
+	total := 0
+	for _, votes := range s.Rating.Distribution {
+		total += votes
+	}
+	return total
+}
+
+// Distribution returns the rating distribution ordered from the highest
+// score to the lowest.
+func (s Statistics) Distribution() []RatingBucket {
+	total := s.Votes()
+	buckets := make([]RatingBucket, 0, 10)
+	for score := 10; score >= 1; score-- {
+		votes := s.Rating.Distribution[strconv.Itoa(score)]
+		bucket := RatingBucket{Score: score, Votes: votes}
+		if total > 0 {
+			bucket.Percent = votes * 100 / total
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// StatisticsResponse is the API response for the manga statistics endpoint.
+type StatisticsResponse struct {
+	Result     string                `json:"result"`
+	Statistics map[string]Statistics `json:"statistics"`
+}
+
+// GetStatistics fetches statistics for the given manga IDs, requesting only
+// the ones not already cached and batching them into as few calls as possible.
+func GetStatistics(ctx context.Context, ids ...string) (map[string]Statistics, error) {
+	stats := make(map[string]Statistics, len(ids))
+	var missing []string
+	for _, id := range ids {
+		if s, ok := statisticsCache.Get(ctx, id); ok {
+			stats[id] = s
+		} else if id != "" {
+			missing = append(missing, id)
+		}
+	}
+
+	for len(missing) > 0 {
+		batch := missing
+		if len(batch) > maxIDsPerRequest {
+			batch = batch[:maxIDsPerRequest]
+		}
+		missing = missing[len(batch):]
+
+		params := url.Values{}
+		for _, id := range batch {
+			params.Add("manga[]", id)
+		}
+		requestURL := fmt.Sprintf("%s/statistics/manga?%s", apiBase, params.Encode())
+		resp, err := get(ctx, requestURL)
+		if err != nil {
+			return stats, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return stats, fmt.Errorf("API returned non-OK status: %s", resp.Status)
+		}
+
+		var result StatisticsResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return stats, err
+		}
+
+		for id, s := range result.Statistics {
+			statisticsCache.Set(id, s)
+			stats[id] = s
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,101 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// tagCache holds the full tag list; it only changes when MangaDex adds tags.
+var tagCache = NewCache[[]Tag]("tags")
+
+// Tag represents a manga tag such as a genre or theme.
+type Tag struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Name  LocalizedString `json:"name"`
+		Group string          `json:"group"` // genre, theme, format or content
+	} `json:"attributes"`
+}
+
+// Name returns the English tag name.
+func (t Tag) Name() string {
+	if name := t.Attributes.Name["en"]; name != "" {
+		return name
+	}
+	for _, name := range t.Attributes.Name {
+		return name
+	}
+	return t.ID
+}
+
+// GetTags fetches every tag known to the API.
+func GetTags(ctx context.Context) ([]Tag, error) {
+	if tags, ok := tagCache.Get(ctx, "all"); ok {
+		return tags, nil
+	}
+
+	requestURL := fmt.Sprintf("%s/manga/tag", apiBase)
+	resp, err := get(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned non-OK status: %s", resp.Status)
+	}
+
+	var result struct {
+		Data []Tag `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	tagCache.Set("all", result.Data)
+	return result.Data, nil
+}
+
+// GetTag returns a single tag by ID from the tag list.
+func GetTag(ctx context.Context, tagID string) (Tag, error) {
+	tags, err := GetTags(ctx)
+	if err != nil {
+		return Tag{}, err
+	}
+	for _, tag := range tags {
+		if tag.ID == tagID {
+			return tag, nil
+		}
+	}
+	return Tag{}, fmt.Errorf("%w: unknown tag %q", ErrNotFound, tagID)
+}
+
+// ResolveTagIDs maps tag IDs or case-insensitive English tag names to IDs.
+func ResolveTagIDs(ctx context.Context, namesOrIDs []string) ([]string, error) {
+	if len(namesOrIDs) == 0 {
+		return nil, nil
+	}
+	tags, err := GetTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, value := range namesOrIDs {
+		found := false
+		for _, tag := range tags {
+			if tag.ID == value || strings.EqualFold(tag.Name(), value) {
+				ids = append(ids, tag.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown tag %q", value)
+		}
+	}
+	return ids, nil
+}
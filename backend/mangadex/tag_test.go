@@ -0,0 +1,30 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestGetTag checks that an unknown tag is reported as not found, so that
+// its page answers 404 rather than a gateway error.
+func TestGetTag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"result": "ok", "data": []any{
+			map[string]any{"id": "tag1", "type": "tag", "attributes": map[string]any{"name": map[string]string{"en": "Action"}}},
+		}})
+	}))
+	t.Cleanup(srv.Close)
+	Configure(Options{APIBase: srv.URL})
+
+	ctx := context.Background()
+	if tag, err := GetTag(ctx, "tag1"); err != nil || tag.Name() != "Action" {
+		t.Errorf("GetTag(tag1) = %q, %v; want Action", tag.Name(), err)
+	}
+	if _, err := GetTag(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTag(nope) err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package metrics defines the Prometheus metrics exported on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "manga"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route pattern and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// UpstreamRequests counts MangaDex API calls by endpoint and status code,
+	// or "error" when no response was received.
+	UpstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "MangaDex API requests, by endpoint and status code.",
+	}, []string{"endpoint", "code"})
+	// UpstreamDuration observes MangaDex API call latency by endpoint.
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of MangaDex API requests, by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+	// RateLimitWaits observes how long requests waited for the rate limiter,
+	// counting only requests that had to wait.
+	RateLimitWaits = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_rate_limit_wait_seconds",
+		Help:      "Time MangaDex API requests waited for the rate limiter.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	})
+
+	// CacheHits, CacheMisses and CacheEvictions count cache activity by
+	// cache name.
+	CacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Cache lookups that found a live entry, by cache.",
+	}, []string{"cache"})
+	CacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Cache lookups that found no live entry, by cache.",
+	}, []string{"cache"})
+	CacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_evictions_total",
+		Help:      "Entries dropped to keep a cache within its size limit, by cache.",
+	}, []string{"cache"})
+
+	// UpdateChecks counts manga feeds polled for new chapters, by result
+	// (ok or error).
+	UpdateChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "update_checks_total",
+		Help:      "Manga chapter feeds polled for new chapters, by result.",
+	}, []string{"result"})
+	// NewChapters counts chapters found by the update checker.
+	NewChapters = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "new_chapters_total",
+		Help:      "New chapters found by the update checker.",
+	})
+	// NotificationDeliveries counts attempts to deliver notifications, by
+	// channel kind and result (ok, retry or failed).
+	NotificationDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_deliveries_total",
+		Help:      "Notification delivery attempts, by channel kind and result.",
+	}, []string{"kind", "result"})
+
+	// ImageProxyBytes counts bytes sent by the image proxy, by whether they
+	// came from the image cache or upstream.
+	ImageProxyBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_proxy_bytes_total",
+		Help:      "Bytes sent by the image proxy, by source (cache or upstream).",
+	}, []string{"source"})
+	// ImageCacheLookups counts image cache lookups by result (hit or miss).
+	ImageCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_cache_lookups_total",
+		Help:      "Image cache lookups, by result (hit or miss).",
+	}, []string{"result"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records the count and latency of requests by chi route
+// pattern, so that /manga/{mangaID} is one series rather than one per
+// manga.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
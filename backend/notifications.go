@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nithish-95/manga/backend/config"
+	"github.com/nithish-95/manga/backend/metrics"
+	"github.com/nithish-95/manga/backend/notify"
+	"github.com/nithish-95/manga/backend/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// maxChannelsPerUser bounds the notification channels of one user.
+	maxChannelsPerUser = 10
+	// deliveryPollInterval is how often the queue is checked for deliveries
+	// that came due, such as retries and email digests.
+	deliveryPollInterval = 15 * time.Second
+	// deliveryBatch is the number of deliveries read from the queue at once.
+	deliveryBatch = 20
+	// deliveryTimeout bounds one attempt at sending a notification.
+	deliveryTimeout = 30 * time.Second
+	// Failed deliveries are retried after firstRetryDelay, doubling up to
+	// maxRetryDelay, and dropped after maxDeliveryAttempts attempts,
+	// some four hours after the first.
+	firstRetryDelay     = 30 * time.Second
+	maxRetryDelay       = 6 * time.Hour
+	maxDeliveryAttempts = 10
+	// directSendInterval is how often a user may have a notification sent
+	// right away, as a test or an email confirmation link.
+	directSendInterval = time.Minute
+)
+
+var (
+	// mailer sends email digests; it is disabled without an SMTP server.
+	mailer *notify.Mailer
+	// publicURL is the site URL notification links point at, if known.
+	publicURL string
+	// digestInterval is how long email digests collect chapters.
+	digestInterval time.Duration
+	// wakeNotifier asks the delivery worker to check the queue now.
+	wakeNotifier = make(chan struct{}, 1)
+	// directSends throttles the notifications users have sent right away.
+	directSends = &sendThrottle{last: make(map[string]time.Time)}
+)
+
+// sendThrottle allows each user one send per directSendInterval.
+type sendThrottle struct {
+	mu   sync.Mutex
+	last map[string]time.Time // user ID -> time of the last send
+}
+
+// take reserves a send for the user at now, or returns how long the user
+// has to wait for one.
+func (t *sendThrottle) take(userID string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, at := range t.last {
+		if now.Sub(at) >= directSendInterval {
+			delete(t.last, id)
+		}
+	}
+	if at, ok := t.last[userID]; ok {
+		return directSendInterval - now.Sub(at)
+	}
+	t.last[userID] = now
+	return 0
+}
+
+// startNotifier configures notification delivery and starts the worker
+// that sends queued notifications until shutdown.
+func startNotifier(cfg config.Notifications) {
+	mailer = &notify.Mailer{
+		Addr:     cfg.SMTP.Addr,
+		Username: cfg.SMTP.Username,
+		Password: cfg.SMTP.Password,
+		From:     cfg.SMTP.From,
+	}
+	publicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	digestInterval = cfg.DigestInterval
+
+	goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(deliveryPollInterval)
+		defer ticker.Stop()
+		for {
+			deliverDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-wakeNotifier:
+			}
+		}
+	})
+}
+
+// queueNotifications queues the new chapters each user got for every one
+// of their channels. Email channels wait digestInterval so that chapters
+// found meanwhile go out in the same email.
+func queueNotifications(ctx context.Context, added map[string][]store.Update) {
+	now := time.Now()
+	for userID, updates := range added {
+		channels, err := db.Channels(userID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error reading notification channels", "user_id", userID, "err", err)
+			continue
+		}
+		for _, ch := range channels {
+			if !ch.Active() {
+				continue
+			}
+			due := now
+			if ch.Kind == store.ChannelEmail {
+				due = now.Add(digestInterval)
+			}
+			if err := db.QueueUpdates(userID, ch.ID, updates, due); err != nil {
+				slog.ErrorContext(ctx, "Error queueing notification", "channel_id", ch.ID, "err", err)
+			}
+		}
+	}
+	select {
+	case wakeNotifier <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue sends every queued notification that is due.
+func deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := db.DueDeliveries(time.Now(), deliveryBatch)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error reading notification queue", "err", err)
+			return
+		}
+		for _, d := range deliveries {
+			deliverQueued(ctx, d)
+		}
+		if len(deliveries) < deliveryBatch {
+			return
+		}
+	}
+}
+
+// deliverQueued makes one attempt at a queued notification, and queues it
+// again with a longer delay if it fails.
+func deliverQueued(ctx context.Context, queued store.Delivery) {
+	// Chapters may have been added since the queue was read.
+	d, err := db.ClaimDelivery(queued.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error claiming notification", "err", err)
+		return
+	}
+
+	ch, err := db.Channel(d.UserID, d.ChannelID)
+	if errors.Is(err, store.ErrNotFound) || err == nil && !ch.Active() {
+		// The channel was removed after the notification was queued, or its
+		// address was never confirmed.
+		if err := db.DeleteDelivery(d.ID); err != nil {
+			slog.ErrorContext(ctx, "Error dropping notification", "err", err)
+		}
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading notification channel", "channel_id", d.ChannelID, "err", err)
+		return
+	}
+
+	err = sendNotification(ctx, ch, notificationMessage(d.Updates))
+	attempts := d.Attempts + 1
+	switch {
+	case err == nil:
+		metrics.NotificationDeliveries.WithLabelValues(string(ch.Kind), "ok").Inc()
+		err = db.DeleteDelivery(d.ID)
+	case attempts >= maxDeliveryAttempts:
+		metrics.NotificationDeliveries.WithLabelValues(string(ch.Kind), "failed").Inc()
+		slog.WarnContext(ctx, "Giving up on notification", "channel_id", ch.ID, "kind", ch.Kind, "attempts", attempts, "err", err)
+		err = db.DeleteDelivery(d.ID)
+	default:
+		metrics.NotificationDeliveries.WithLabelValues(string(ch.Kind), "retry").Inc()
+		delay := retryDelay(attempts)
+		slog.InfoContext(ctx, "Notification failed, will retry", "channel_id", ch.ID, "kind", ch.Kind, "attempts", attempts, "retry_in", delay, "err", err)
+		err = db.RetryDelivery(*d, time.Now().Add(delay), err)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error updating notification queue", "err", err)
+	}
+}
+
+// retryDelay is how long to wait after the given number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// sendNotification delivers a message to a channel.
+func sendNotification(ctx context.Context, ch *store.Channel, msg notify.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "notification delivery",
+		trace.WithAttributes(attribute.String("notification.kind", string(ch.Kind)), attribute.Int("notification.chapters", len(msg.Chapters))))
+	defer span.End()
+
+	var err error
+	switch ch.Kind {
+	case store.ChannelWebhook:
+		err = notify.Webhook(ctx, ch.Target, ch.Secret, msg)
+	case store.ChannelDiscord:
+		err = notify.Discord(ctx, ch.Target, msg)
+	case store.ChannelEmail:
+		err = mailer.Email(ctx, ch.Target, msg)
+	default:
+		err = fmt.Errorf("unknown channel kind %q", ch.Kind)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// notificationMessage turns updates into a notification, with links to the
+// reader when the public URL is configured.
+func notificationMessage(updates []store.Update) notify.Message {
+	var msg notify.Message
+	for _, u := range updates {
+		c := notify.Chapter{
+			MangaID:    u.MangaID,
+			MangaTitle: u.MangaTitle,
+			ChapterID:  u.ChapterID,
+			Chapter:    u.Chapter,
+			Language:   u.Language,
+			Published:  u.Published,
+		}
+		if publicURL != "" {
+			c.URL = publicURL + "/manga/" + url.PathEscape(u.MangaID) + "/read/" + url.PathEscape(u.ChapterID)
+		}
+		msg.Chapters = append(msg.Chapters, c)
+	}
+	return msg
+}
+
+// notificationsHandler shows the user's notification channels.
+func notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	renderNotifications(w, r, "", "")
+}
+
+// renderNotifications shows the notification settings with a message
+// about the last action, or the error it failed with.
+func renderNotifications(w http.ResponseWriter, r *http.Request, notice, formError string) {
+	user := currentUser(r)
+	channels, err := db.Channels(user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading notification channels", "err", err)
+		http.Error(w, "Error reading notification settings", http.StatusInternalServerError)
+		return
+	}
+	pending, err := db.PendingDeliveries(user.ID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error reading notification queue", "err", err)
+	}
+
+	var kinds []store.ChannelKind
+	for _, kind := range store.ChannelKinds {
+		if kind != store.ChannelEmail || mailer.Enabled() {
+			kinds = append(kinds, kind)
+		}
+	}
+	data := struct {
+		Channels       []store.Channel
+		Pending        map[string]int
+		Kinds          []store.ChannelKind
+		DigestInterval time.Duration
+		Notice         string
+		Error          string
+	}{
+		Channels:       channels,
+		Pending:        pending,
+		Kinds:          kinds,
+		DigestInterval: digestInterval,
+		Notice:         notice,
+		Error:          formError,
+	}
+	render(w, r, "notifications", data)
+}
+
+// addChannelHandler adds a notification channel.
+func addChannelHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	user := currentUser(r)
+	kind := store.ChannelKind(r.PostForm.Get("kind"))
+	target := strings.TrimSpace(r.PostForm.Get("target"))
+
+	channels, err := db.Channels(user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading notification channels", "err", err)
+		http.Error(w, "Error reading notification settings", http.StatusInternalServerError)
+		return
+	}
+	var formError string
+	switch {
+	case !kind.Valid():
+		formError = "Choose a kind of notification."
+	case len(channels) >= maxChannelsPerUser:
+		formError = fmt.Sprintf("You can have at most %d notification channels.", maxChannelsPerUser)
+	case kind == store.ChannelEmail && !mailer.Enabled():
+		formError = "Email is not available on this server."
+	case kind == store.ChannelEmail:
+		if addr, err := mail.ParseAddress(target); err != nil {
+			formError = "Enter a valid email address."
+		} else {
+			target = addr.Address
+		}
+	case !validWebhookURL(target):
+		formError = "Enter the public http or https URL of the webhook."
+	}
+	if formError != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		renderNotifications(w, r, "", formError)
+		return
+	}
+	// Email channels are confirmed by mail, which counts as a send.
+	if kind == store.ChannelEmail {
+		if wait := directSends.take(user.ID, time.Now()); wait > 0 {
+			renderTooSoon(w, r, wait)
+			return
+		}
+	}
+
+	ch, err := db.AddChannel(user.ID, kind, target)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error adding notification channel", "err", err)
+		http.Error(w, "Error saving notification settings", http.StatusInternalServerError)
+		return
+	}
+	if !ch.Active() {
+		sendConfirmation(w, r, ch)
+		return
+	}
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// sendConfirmation emails the link that confirms an email channel and
+// reports how it went.
+func sendConfirmation(w http.ResponseWriter, r *http.Request, ch *store.Channel) {
+	link := siteURL(r) + "/notifications/" + url.PathEscape(ch.ID) + "/confirm?token=" + url.QueryEscape(ch.ConfirmToken)
+	if err := sendNotification(r.Context(), ch, notify.Message{ConfirmURL: link}); err != nil {
+		slog.InfoContext(r.Context(), "Confirmation email failed", "channel_id", ch.ID, "err", err)
+		renderNotifications(w, r, "", fmt.Sprintf("The confirmation email to %s failed: %v", ch.Target, err))
+		return
+	}
+	renderNotifications(w, r, "We sent a link to "+ch.Target+". Open it to start getting notifications there.", "")
+}
+
+// confirmChannelHandler confirms an email channel from the link sent to
+// its address.
+func confirmChannelHandler(w http.ResponseWriter, r *http.Request) {
+	ch, err := db.ConfirmChannel(currentUser(r).ID, chi.URLParam(r, "channelID"), r.URL.Query().Get("token"))
+	if errors.Is(err, store.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		renderNotifications(w, r, "", "This confirmation link is not valid. Send a new one from the channel below.")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error confirming notification channel", "err", err)
+		http.Error(w, "Error saving notification settings", http.StatusInternalServerError)
+		return
+	}
+	renderNotifications(w, r, "Notifications will be sent to "+ch.Target+".", "")
+}
+
+// renderTooSoon tells the user how long to wait before the next send.
+func renderTooSoon(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+	w.WriteHeader(http.StatusTooManyRequests)
+	renderNotifications(w, r, "", fmt.Sprintf("Wait %v before sending another notification.", wait.Round(time.Second)))
+}
+
+// validWebhookURL reports whether s is an http or https URL that is not
+// obviously on the server's own network. Names resolving to such addresses
+// are only caught when sending.
+func validWebhookURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil {
+		return notify.PublicIP(ip)
+	}
+	return u.Hostname() != "localhost" && !strings.HasSuffix(u.Hostname(), ".localhost")
+}
+
+// deleteChannelHandler removes a notification channel.
+func deleteChannelHandler(w http.ResponseWriter, r *http.Request) {
+	if err := db.DeleteChannel(currentUser(r).ID, chi.URLParam(r, "channelID")); err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting notification channel", "err", err)
+		http.Error(w, "Error saving notification settings", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// testChannelHandler sends a test notification to a channel right away,
+// bypassing the queue, and reports how it went. Email channels that are not
+// confirmed get the confirmation link again instead.
+func testChannelHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	ch, err := db.Channel(user.ID, chi.URLParam(r, "channelID"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading notification channel", "err", err)
+		http.Error(w, "Error reading notification settings", http.StatusInternalServerError)
+		return
+	}
+	if wait := directSends.take(user.ID, time.Now()); wait > 0 {
+		renderTooSoon(w, r, wait)
+		return
+	}
+	if !ch.Active() {
+		sendConfirmation(w, r, ch)
+		return
+	}
+
+	if err := sendNotification(r.Context(), ch, notify.Message{Test: true}); err != nil {
+		slog.InfoContext(r.Context(), "Test notification failed", "channel_id", ch.ID, "kind", ch.Kind, "err", err)
+		renderNotifications(w, r, "", fmt.Sprintf("The test to %s failed: %v", ch.Target, err))
+		return
+	}
+	renderNotifications(w, r, "Test notification sent to "+ch.Target+".", "")
+}
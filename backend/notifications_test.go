@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nithish-95/manga/backend/store"
+)
+
+// openTestDB points db at a new database for the duration of the test.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	var err error
+	db, err = store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close(); db = nil })
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  firstRetryDelay,
+		2:  2 * firstRetryDelay,
+		5:  16 * firstRetryDelay,
+		10: 512 * firstRetryDelay,
+		11: maxRetryDelay,
+		50: maxRetryDelay,
+	} {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+
+	var total time.Duration
+	for attempts := 1; attempts < maxDeliveryAttempts; attempts++ {
+		total += retryDelay(attempts)
+	}
+	if total < 4*time.Hour || total > 5*time.Hour {
+		t.Errorf("last attempt is %v after the first, want some four hours", total)
+	}
+}
+
+// TestQueueUpdatesWhileSending checks that chapters found while a delivery
+// is being sent are queued separately and survive it being removed, and
+// that deliveries are counted per channel.
+func TestQueueUpdatesWhileSending(t *testing.T) {
+	openTestDB(t)
+	user, err := db.CreateUser("reader", []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := db.AddChannel(user.ID, store.ChannelEmail, "reader@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := func(chapterID string) {
+		t.Helper()
+		update := store.Update{MangaID: "m1", MangaTitle: "Manga", ChapterID: chapterID}
+		if err := db.QueueUpdates(user.ID, ch.ID, []store.Update{update}, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chapters := func(d store.Delivery) (ids []string) {
+		for _, u := range d.Updates {
+			ids = append(ids, u.ChapterID)
+		}
+		return ids
+	}
+
+	queue("c1")
+	queue("c2")
+	due, err := db.DueDeliveries(time.Now(), 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("got %d due deliveries, err %v; want 1", len(due), err)
+	}
+	sending, err := db.ClaimDelivery(due[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chapters(*sending); !slices.Equal(got, []string{"c1", "c2"}) {
+		t.Errorf("claimed %v, want [c1 c2]", got)
+	}
+
+	queue("c3")
+	other, err := db.AddChannel(user.ID, store.ChannelDiscord, "https://discord.example/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueueUpdates(user.ID, other.ID, []store.Update{{ChapterID: "c3"}}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := db.PendingDeliveries(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{ch.ID: 2, other.ID: 1}; !maps.Equal(pending, want) {
+		t.Errorf("pending = %v, want %v", pending, want)
+	}
+
+	if err := db.DeleteDelivery(sending.ID); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = db.PendingDeliveries(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{ch.ID: 1, other.ID: 1}; !maps.Equal(pending, want) {
+		t.Errorf("pending after the send = %v, want %v", pending, want)
+	}
+	due, err = db.DueDeliveries(time.Now(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range due {
+		if got := chapters(d); d.ChannelID == ch.ID && !slices.Equal(got, []string{"c3"}) {
+			t.Errorf("queued %v after the send, want [c3]", got)
+		}
+	}
+}
+
+// TestDeliverQueued fails a delivery to a webhook on a private address and
+// checks that it is queued again with the backoff delay, dropped once out
+// of attempts, and dropped when its channel is gone.
+func TestDeliverQueued(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	user, err := db.CreateUser("reader", []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := db.AddChannel(user.ID, store.ChannelWebhook, "http://127.0.0.1:9/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := func(attempts int) store.Delivery {
+		t.Helper()
+		update := store.Update{MangaID: "m1", MangaTitle: "Manga", ChapterID: "c1", Chapter: "1"}
+		if err := db.QueueUpdates(user.ID, ch.ID, []store.Update{update}, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		due := func() store.Delivery {
+			t.Helper()
+			due, err := db.DueDeliveries(time.Now(), 10)
+			if err != nil || len(due) != 1 {
+				t.Fatalf("got %d due deliveries, err %v; want 1", len(due), err)
+			}
+			return due[0]
+		}
+		for range attempts {
+			d, err := db.ClaimDelivery(due().ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.RetryDelivery(*d, time.Now(), errors.New("earlier attempt")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return due()
+	}
+	queued := func() []store.Delivery {
+		t.Helper()
+		all, err := db.DueDeliveries(time.Now().Add(24*time.Hour), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return all
+	}
+
+	before := time.Now()
+	deliverQueued(ctx, queue(2))
+	after := time.Now()
+	all := queued()
+	if len(all) != 1 {
+		t.Fatalf("got %d queued deliveries, want 1", len(all))
+	}
+	d := all[0]
+	if d.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", d.Attempts)
+	}
+	if !strings.Contains(d.LastError, "not public") {
+		t.Errorf("last error = %q, want the private address refused", d.LastError)
+	}
+	delay := retryDelay(3)
+	if d.Due.Before(before.Add(delay).Truncate(time.Second)) || d.Due.After(after.Add(delay)) {
+		t.Errorf("due %v, want %v after the attempt", d.Due.Sub(before), delay)
+	}
+	if err := db.DeleteDelivery(d.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	deliverQueued(ctx, queue(maxDeliveryAttempts-1))
+	if n := len(queued()); n != 0 {
+		t.Errorf("%d deliveries queued after the last attempt, want none", n)
+	}
+
+	d = queue(0)
+	if err := db.DeleteChannel(user.ID, ch.ID); err != nil {
+		t.Fatal(err)
+	}
+	deliverQueued(ctx, d)
+	if n := len(queued()); n != 0 {
+		t.Errorf("%d deliveries queued for a removed channel, want none", n)
+	}
+}
+
+func TestSendThrottle(t *testing.T) {
+	throttle := &sendThrottle{last: make(map[string]time.Time)}
+	now := time.Now()
+	if wait := throttle.take("a", now); wait != 0 {
+		t.Fatalf("first send waits %v", wait)
+	}
+	if wait := throttle.take("a", now.Add(10*time.Second)); wait != directSendInterval-10*time.Second {
+		t.Errorf("second send waits %v, want %v", wait, directSendInterval-10*time.Second)
+	}
+	if wait := throttle.take("b", now.Add(10*time.Second)); wait != 0 {
+		t.Errorf("another user waits %v", wait)
+	}
+	if wait := throttle.take("a", now.Add(directSendInterval)); wait != 0 {
+		t.Errorf("send after the interval waits %v", wait)
+	}
+	if len(throttle.last) != 2 {
+		t.Errorf("%d users remembered, want 2", len(throttle.last))
+	}
+}
+
+// TestEmailChannelConfirmation checks that email channels get nothing
+// queued until the link sent to them is opened.
+func TestEmailChannelConfirmation(t *testing.T) {
+	openTestDB(t)
+	user, err := db.CreateUser("reader", []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := db.AddChannel(user.ID, store.ChannelEmail, "reader@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Active() || ch.ConfirmToken == "" {
+		t.Fatalf("new email channel active %v with token %q", ch.Active(), ch.ConfirmToken)
+	}
+	added := map[string][]store.Update{user.ID: {{MangaID: "m1", ChapterID: "c1"}}}
+	pending := func() int {
+		t.Helper()
+		pending, err := db.PendingDeliveries(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pending[ch.ID]
+	}
+
+	queueNotifications(context.Background(), added)
+	if n := pending(); n != 0 {
+		t.Errorf("%d deliveries queued before confirmation, want none", n)
+	}
+
+	if _, err := db.ConfirmChannel(user.ID, ch.ID, "wrong"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("confirming with the wrong token: err %v, want ErrNotFound", err)
+	}
+	confirmed, err := db.ConfirmChannel(user.ID, ch.ID, ch.ConfirmToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmed.Active() || confirmed.ConfirmToken != "" {
+		t.Errorf("confirmed channel active %v with token %q", confirmed.Active(), confirmed.ConfirmToken)
+	}
+	if _, err := db.ConfirmChannel(user.ID, ch.ID, ch.ConfirmToken); err != nil {
+		t.Errorf("confirming again: %v", err)
+	}
+
+	queueNotifications(context.Background(), added)
+	if n := pending(); n != 1 {
+		t.Errorf("%d deliveries queued after confirmation, want 1", n)
+	}
+}
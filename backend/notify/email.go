@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer sends email digests through an SMTP server. STARTTLS is used when
+// the server offers it; the login, if any, is only sent over TLS or to a
+// server on localhost.
+type Mailer struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string // sender, as an address or "Name <address>"
+}
+
+// Enabled reports whether a mail server is configured.
+func (m *Mailer) Enabled() bool {
+	return m != nil && m.Addr != ""
+}
+
+// Email sends the message as a plain-text digest to the address to.
+func (m *Mailer) Email(ctx context.Context, to string, msg Message) error {
+	if !m.Enabled() {
+		return fmt.Errorf("email is not configured on this server")
+	}
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("sender address: %w", err)
+	}
+	body := digest(from.String(), addr.Address, msg)
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	// net/smtp takes no context, so the send runs on its own and is
+	// abandoned if ctx ends first.
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(m.Addr, auth, from.Address, []string{addr.Address}, body) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// digest formats the email: headers, then each chapter on its own line
+// followed by its link.
+func digest(from, to string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Summary()))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+
+	if msg.ConfirmURL != "" {
+		b.WriteString("Open this link to get new chapters of the manga in your library sent to this address:\r\n\r\n")
+		b.WriteString("  " + msg.ConfirmURL + "\r\n\r\n")
+		b.WriteString("If you did not ask for this, ignore this email and nothing will be sent.\r\n")
+		return b.Bytes()
+	}
+	if msg.Test {
+		b.WriteString("This is a test. New chapters of the manga in your library will be sent to this address.\r\n")
+		return b.Bytes()
+	}
+	b.WriteString("New chapters of the manga in your library:\r\n\r\n")
+	for _, c := range msg.Chapters {
+		line := c.MangaTitle + " — " + c.Chapter
+		if c.Language != "" {
+			line += " [" + strings.ToUpper(c.Language) + "]"
+		}
+		b.WriteString(line + "\r\n")
+		if c.URL != "" {
+			b.WriteString("  " + c.URL + "\r\n")
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// smtpMessage is what the fake SMTP server received.
+type smtpMessage struct {
+	from, to string
+	data     string
+}
+
+// fakeSMTP accepts one message on a local listener and sends it on the
+// returned channel. It offers no extensions, so no STARTTLS or login.
+func fakeSMTP(t *testing.T) (addr string, received <-chan smtpMessage) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		var msg smtpMessage
+		tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 fake")
+			case "MAIL":
+				msg.from = arg
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				msg.to = arg
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				msg.data = string(data)
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				out <- msg
+				return
+			default:
+				tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestEmail(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	m := &Mailer{Addr: addr, From: "Manga <manga@example.com>"}
+	chapters := testChapters(1)
+	chapters[0].MangaTitle = "Frieren"
+	chapters[0].Language = "en"
+	chapters[0].URL = "https://manga.example.com/manga/m1/chapter/c1"
+
+	if err := m.Email(context.Background(), "Reader <reader@example.com>", Message{Chapters: chapters}); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-received
+	if msg.from != "FROM:<manga@example.com>" || msg.to != "TO:<reader@example.com>" {
+		t.Errorf("envelope from %q to %q", msg.from, msg.to)
+	}
+	header, body, _ := strings.Cut(msg.data, "\n\n")
+	for _, want := range []string{
+		`From: "Manga" <manga@example.com>`,
+		"To: reader@example.com",
+		"Subject: New chapter of Frieren",
+		"Content-Type: text/plain; charset=utf-8",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header lacks %q:\n%s", want, header)
+		}
+	}
+	for _, want := range []string{"Frieren — Ch. 1 [EN]", "  " + chapters[0].URL} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestEmailErrors(t *testing.T) {
+	ctx := context.Background()
+	var disabled *Mailer
+	if err := disabled.Email(ctx, "reader@example.com", Message{Test: true}); err == nil {
+		t.Error("disabled mailer sent")
+	}
+	m := &Mailer{Addr: "127.0.0.1:1", From: "manga@example.com"}
+	if err := m.Email(ctx, "not an address", Message{Test: true}); err == nil {
+		t.Error("invalid recipient accepted")
+	}
+}
+
+func TestConfirmationEmail(t *testing.T) {
+	link := "https://manga.example.com/notifications/ch1/confirm?token=secret"
+	header, body, _ := strings.Cut(string(digest("manga@example.com", "reader@example.com", Message{ConfirmURL: link})), "\r\n\r\n")
+	if !strings.Contains(header, "Subject: Confirm your email notifications") {
+		t.Errorf("header lacks the confirmation subject:\n%s", header)
+	}
+	if !strings.Contains(body, "  "+link+"\r\n") {
+		t.Errorf("body lacks the link:\n%s", body)
+	}
+}
@@ -0,0 +1,131 @@
+// Package notify sends new-chapter notifications to generic webhooks,
+// Discord webhooks and email.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// userAgent identifies the server to webhook receivers.
+const userAgent = "manga-notify/1"
+
+// Chapter is a new chapter to tell a user about.
+type Chapter struct {
+	MangaID    string    `json:"mangaId"`
+	MangaTitle string    `json:"mangaTitle"`
+	ChapterID  string    `json:"chapterId"`
+	Chapter    string    `json:"chapter"`
+	Language   string    `json:"language,omitempty"`
+	Published  time.Time `json:"published"`
+	URL        string    `json:"url,omitempty"` // reader link, when the public URL is configured
+}
+
+// Message is one notification: the chapters found since the last one.
+type Message struct {
+	Chapters   []Chapter
+	Test       bool   // sent from the test button rather than by the update checker
+	ConfirmURL string // asks the owner of a new email address to open this link
+}
+
+// Summary describes the message in one line, for subjects and titles.
+func (m Message) Summary() string {
+	switch {
+	case m.ConfirmURL != "":
+		return "Confirm your email notifications"
+	case m.Test:
+		return "Test notification"
+	case len(m.Chapters) == 1:
+		return "New chapter of " + m.Chapters[0].MangaTitle
+	}
+	return fmt.Sprintf("%d new chapters", len(m.Chapters))
+}
+
+// client sends webhook requests. Receivers get a short time to answer;
+// failed deliveries are retried by the caller. Webhook URLs are chosen by
+// users, so the client only connects to public addresses, checked after
+// the name is resolved, and does not follow redirects, which could lead
+// anywhere. It ignores proxy settings for the same reason.
+var client = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: publicOnly}).DialContext,
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// ErrPrivateAddress is returned for webhooks on a loopback, private or
+// otherwise non-public address.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// reservedPrefixes are non-public ranges that netip has no predicate for.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+}
+
+// PublicIP reports whether ip is an address webhooks may be sent to.
+func PublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicOnly is a net.Dialer Control function refusing connections to
+// addresses that are not public.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicIP(addr.Addr()) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// postJSON posts body to url and fails unless the receiver answers 2xx.
+// The error names only the status: receivers' answers are not shown to the
+// user, who may not be the receiver's owner.
+func postJSON(ctx context.Context, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook event names, sent in the X-Manga-Event header and the payload.
+const (
+	EventChapters = "chapters"
+	EventTest     = "test"
+)
+
+// webhookPayload is the JSON body of a generic webhook.
+type webhookPayload struct {
+	Event    string    `json:"event"`
+	Sent     time.Time `json:"sent"`
+	Chapters []Chapter `json:"chapters"`
+}
+
+// Webhook posts the message to url as JSON. The request carries the Unix
+// time in X-Manga-Timestamp and, in X-Manga-Signature, "sha256=" followed by
+// the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with
+// secret. Receivers recompute it to check the request came from this server.
+func Webhook(ctx context.Context, url, secret string, m Message) error {
+	event := EventChapters
+	if m.Test {
+		event = EventTest
+	}
+	now := time.Now().UTC()
+	payload := webhookPayload{Event: event, Sent: now, Chapters: m.Chapters}
+	if payload.Chapters == nil {
+		payload.Chapters = []Chapter{}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header := http.Header{}
+	header.Set("X-Manga-Event", event)
+	header.Set("X-Manga-Timestamp", timestamp)
+	header.Set("X-Manga-Signature", "sha256="+Sign(secret, timestamp, body))
+	return postJSON(ctx, url, body, header)
+}
+
+// Sign returns the hex signature of a webhook body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Discord limits; longer messages are cut short.
+const (
+	discordMaxEmbeds  = 10
+	discordMaxContent = 2000
+)
+
+type discordPayload struct {
+	Username string         `json:"username"`
+	Content  string         `json:"content"`
+	Embeds   []discordEmbed `json:"embeds,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url,omitempty"`
+	Timestamp   string `json:"timestamp,omitempty"`
+}
+
+// Discord posts the message to a Discord-compatible webhook URL, one embed
+// per chapter.
+func Discord(ctx context.Context, url string, m Message) error {
+	p := discordPayload{Username: "Manga", Content: m.Summary()}
+	if m.Test {
+		p.Content = "Test notification: new chapters of the manga in your library will be posted here."
+	}
+	for i, c := range m.Chapters {
+		if i == discordMaxEmbeds {
+			p.Content += fmt.Sprintf("\n…and %d more.", len(m.Chapters)-i)
+			break
+		}
+		p.Embeds = append(p.Embeds, discordEmbed{
+			Title:       truncate(c.MangaTitle, 256),
+			Description: c.Chapter,
+			URL:         c.URL,
+			Timestamp:   c.Published.Format(time.RFC3339),
+		})
+	}
+	p.Content = truncate(p.Content, discordMaxContent)
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, url, body, nil)
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return strings.TrimSpace(string(r[:n-1])) + "…"
+	}
+	return s
+}
@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// allowLoopback lets the client reach test servers, which listen on
+// loopback, for the rest of the test. Redirects stay off.
+func allowLoopback(t *testing.T) {
+	saved := client
+	c := *client
+	c.Transport = http.DefaultTransport
+	client = &c
+	t.Cleanup(func() { client = saved })
+}
+
+// receiver records the last request it got and answers with status.
+type receiver struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.header = r.Header
+	rcv.body, _ = io.ReadAll(r.Body)
+	w.WriteHeader(rcv.status)
+}
+
+func testChapters(n int) []Chapter {
+	chapters := make([]Chapter, n)
+	for i := range chapters {
+		chapters[i] = Chapter{MangaID: "m1", MangaTitle: "Manga", ChapterID: "c1", Chapter: "Ch. 1", Published: time.Unix(0, 0).UTC()}
+	}
+	return chapters
+}
+
+func TestWebhookSignature(t *testing.T) {
+	allowLoopback(t)
+	rcv := &receiver{status: http.StatusNoContent}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	if err := Webhook(context.Background(), srv.URL, "s3cret", Message{Chapters: testChapters(2)}); err != nil {
+		t.Fatal(err)
+	}
+	timestamp := rcv.header.Get("X-Manga-Timestamp")
+	if want := "sha256=" + Sign("s3cret", timestamp, rcv.body); rcv.header.Get("X-Manga-Signature") != want {
+		t.Errorf("signature = %q, want %q", rcv.header.Get("X-Manga-Signature"), want)
+	}
+	if rcv.header.Get("X-Manga-Signature") == "sha256="+Sign("other", timestamp, rcv.body) {
+		t.Error("signature does not depend on the secret")
+	}
+	if got := rcv.header.Get("X-Manga-Event"); got != EventChapters {
+		t.Errorf("event header = %q, want %q", got, EventChapters)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(rcv.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != EventChapters || len(payload.Chapters) != 2 {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	if err := Webhook(context.Background(), srv.URL, "s3cret", Message{Test: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rcv.body), `"event":"test"`) || !strings.Contains(string(rcv.body), `"chapters":[]`) {
+		t.Errorf("test payload = %s", rcv.body)
+	}
+}
+
+func TestDiscordLimits(t *testing.T) {
+	allowLoopback(t)
+	rcv := &receiver{status: http.StatusNoContent}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	chapters := testChapters(discordMaxEmbeds + 5)
+	chapters[0].MangaTitle = strings.Repeat("あ", 300)
+	if err := Discord(context.Background(), srv.URL, Message{Chapters: chapters}); err != nil {
+		t.Fatal(err)
+	}
+	var payload discordPayload
+	if err := json.Unmarshal(rcv.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Embeds) != discordMaxEmbeds {
+		t.Errorf("got %d embeds, want %d", len(payload.Embeds), discordMaxEmbeds)
+	}
+	if !strings.HasSuffix(payload.Content, "…and 5 more.") {
+		t.Errorf("content = %q, want the rest counted", payload.Content)
+	}
+	if title := payload.Embeds[0].Title; utf8.RuneCountInString(title) != 256 || !strings.HasSuffix(title, "…") {
+		t.Errorf("long title is %d runes, want 256 ending in an ellipsis", utf8.RuneCountInString(title))
+	}
+
+	long := truncate(strings.Repeat("x", 3000), discordMaxContent)
+	if utf8.RuneCountInString(long) != discordMaxContent {
+		t.Errorf("truncated content is %d runes, want %d", utf8.RuneCountInString(long), discordMaxContent)
+	}
+}
+
+func TestPostJSONHidesReplies(t *testing.T) {
+	allowLoopback(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal detail", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	err := postJSON(context.Background(), srv.URL, []byte("{}"), nil)
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("err = %v, want the status", err)
+	}
+	if strings.Contains(err.Error(), "internal detail") {
+		t.Errorf("err = %v, includes the receiver's reply", err)
+	}
+}
+
+func TestPostJSONDoesNotFollowRedirects(t *testing.T) {
+	allowLoopback(t)
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) { followed = true })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	if err := postJSON(context.Background(), srv.URL+"/hook", []byte("{}"), nil); err == nil {
+		t.Error("redirect treated as success")
+	}
+	if followed {
+		t.Error("redirect was followed")
+	}
+}
+
+func TestPostJSONRefusesPrivateAddresses(t *testing.T) {
+	reached := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+	defer srv.Close()
+
+	if err := postJSON(context.Background(), srv.URL, []byte("{}"), nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("err = %v, want ErrPrivateAddress", err)
+	}
+	if reached {
+		t.Error("loopback receiver was reached")
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"255.255.255.255":  false,
+		"224.0.0.1":        false,
+		"::1":              false,
+		"::":               false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+		"::ffff:10.0.0.1":  false,
+	} {
+		if got := PublicIP(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("PublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nithish-95/manga/backend/catalog"
+	"github.com/nithish-95/manga/backend/cbz"
+	"github.com/nithish-95/manga/backend/mangadex"
+	"github.com/nithish-95/manga/backend/opds"
+	"github.com/nithish-95/manga/backend/store"
+)
+
+// Catalog page sizes: manga per navigation page and chapters per manga
+// page.
+const (
+	opdsPageSize        = 25
+	opdsChapterPageSize = 100
+)
+
+// opdsRootHandler serves the start of the OPDS catalog, linking to the
+// popular and recent lists and the user's library.
+func opdsRootHandler(w http.ResponseWriter, r *http.Request) {
+	site := siteURL(r)
+	f := newCatalogFeed(r, tagURI(site, "opds"), siteName, opds.NavigationType)
+	entry := func(id, title, summary, rel, path string) opds.Entry {
+		return opds.Entry{
+			ID:      tagURI(site, "opds/"+id),
+			Title:   title,
+			Updated: f.Updated,
+			Summary: summary,
+			Links:   []opds.Link{{Rel: rel, Href: site + path, Type: opds.NavigationType}},
+		}
+	}
+	f.Entries = []opds.Entry{
+		entry("popular", "Popular", "The most followed manga.", opds.RelPopular, "/opds/popular"),
+		entry("recent", "Recently updated", "Manga with new chapters.", opds.RelNew, "/opds/recent"),
+		entry("library", "My library", "The manga in your library. Requires logging in.", opds.RelSubsection, "/opds/library"),
+	}
+	serveCatalog(w, r, f, opds.NavigationType)
+}
+
+// opdsListHandler returns a handler serving a page of the popular or
+// recently updated manga.
+func opdsListHandler(kind catalog.ListKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := catalogService.List(r.Context(), kind, pageParam(r), opdsPageSize, requestFilter(r))
+		if err != nil {
+			opdsError(w, r, err, "manga list")
+			return
+		}
+		site := siteURL(r)
+		f := newCatalogFeed(r, tagURI(site, "opds/"+string(kind)), list.Title, opds.NavigationType)
+		paginate(f, r, list.Pagination, opds.NavigationType)
+		for _, manga := range list.Mangas {
+			f.Entries = append(f.Entries, mangaEntry(site, manga, f.Updated))
+		}
+		serveCatalog(w, r, f, opds.NavigationType)
+	}
+}
+
+// opdsSearchHandler serves a page of the manga matching ?q=.
+func opdsSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "Missing search query", http.StatusBadRequest)
+		return
+	}
+	list, err := catalogService.Search(r.Context(), query, pageParam(r), opdsPageSize, requestFilter(r))
+	if err != nil {
+		opdsError(w, r, err, "search results")
+		return
+	}
+	site := siteURL(r)
+	f := newCatalogFeed(r, tagURI(site, "opds/search?q="+url.QueryEscape(query)), list.Title, opds.NavigationType)
+	paginate(f, r, list.Pagination, opds.NavigationType)
+	for _, manga := range list.Mangas {
+		f.Entries = append(f.Entries, mangaEntry(site, manga, f.Updated))
+	}
+	serveCatalog(w, r, f, opds.NavigationType)
+}
+
+// opdsSearchDescriptionHandler serves the OpenSearch description readers
+// build search URLs from.
+func opdsSearchDescriptionHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := opds.OpenSearchDescription(siteName, "Search "+siteName+" for manga",
+		siteURL(r)+"/opds/search?q={searchTerms}")
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding search description", "err", err)
+		http.Error(w, "Error encoding search description", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", opds.OpenSearchType+"; charset=utf-8")
+	w.Write(body)
+}
+
+// opdsLibraryHandler serves the manga in the user's library, shelf by
+// shelf.
+func opdsLibraryHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	entries, err := db.Library(user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading library", "err", err)
+		http.Error(w, "Error reading library", http.StatusInternalServerError)
+		return
+	}
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.MangaID
+	}
+	byID := make(map[string]mangadex.Manga)
+	for _, manga := range catalogService.Mangas(r.Context(), ids, contentFilter(r)) {
+		byID[manga.ID] = manga
+	}
+
+	site := siteURL(r)
+	f := newCatalogFeed(r, tagURI(site, "opds/library/"+user.ID), user.Username+"'s library", opds.NavigationType)
+	for _, status := range store.ReadingStatuses {
+		for _, entry := range entries {
+			if manga, ok := byID[entry.MangaID]; ok && entry.Status == status {
+				f.Entries = append(f.Entries, mangaEntry(site, manga, entry.Updated))
+			}
+		}
+	}
+	w.Header().Set("Cache-Control", "private, no-cache")
+	serveCatalog(w, r, f, opds.NavigationType)
+}
+
+// opdsMangaHandler serves a page of a manga's chapters, each downloadable
+// as a CBZ file or streamable page by page.
+func opdsMangaHandler(w http.ResponseWriter, r *http.Request) {
+	mangaID := chi.URLParam(r, "mangaID")
+	detail, err := catalogService.MangaDetail(r.Context(), mangaID, pageParam(r), opdsChapterPageSize, requestFilter(r))
+	if err != nil {
+		opdsError(w, r, err, "manga "+mangaID)
+		return
+	}
+	manga := detail.Manga
+	site := siteURL(r)
+	f := newCatalogFeed(r, "urn:uuid:"+manga.ID, manga.GetTitle(), opds.AcquisitionType)
+	f.Links = append(f.Links,
+		opds.Link{Rel: opds.RelUp, Href: site + "/opds", Type: opds.NavigationType},
+		opds.Link{Rel: opds.RelAlternate, Href: site + "/manga/" + url.PathEscape(manga.ID), Type: opds.HTMLType},
+	)
+	f.Links = append(f.Links, coverLinks(site, manga)...)
+	paginate(f, r, detail.Pagination, opds.AcquisitionType)
+
+	var authors []string
+	for _, a := range manga.Authors() {
+		authors = append(authors, a.Name())
+	}
+	for _, c := range detail.Chapters {
+		entry := opds.Entry{
+			ID:        "urn:uuid:" + c.ID,
+			Title:     c.Heading(),
+			Updated:   c.Attributes.PublishAt,
+			Published: c.Attributes.PublishAt,
+			Authors:   authors,
+			Summary:   chapterSummary(manga.GetTitle(), c.Heading(), c.Attributes.TranslatedLanguage),
+			Language:  c.Attributes.TranslatedLanguage,
+		}
+		switch {
+		case c.IsExternal():
+			entry.Links = append(entry.Links, opds.Link{Rel: opds.RelAlternate, Href: c.Attributes.ExternalURL, Type: opds.HTMLType})
+		case !c.Attributes.IsUnavailable && c.Attributes.Pages > 0:
+			entry.Links = append(entry.Links,
+				opds.Link{
+					Rel:   opds.RelStream,
+					Href:  site + "/opds/chapter/" + url.PathEscape(c.ID) + "/page/{pageNumber}",
+					Type:  "image/jpeg",
+					Count: c.Attributes.Pages,
+				},
+				opds.Link{Rel: opds.RelAcquisition, Href: readerURL(site, manga.ID, c.ID) + "/download.cbz", Type: cbz.ContentType},
+				opds.Link{Rel: opds.RelAlternate, Href: readerURL(site, manga.ID, c.ID), Type: opds.HTMLType},
+			)
+		}
+		f.Entries = append(f.Entries, entry)
+	}
+	serveCatalog(w, r, f, opds.AcquisitionType)
+}
+
+// opdsPageHandler streams one page of a chapter through the image proxy.
+// Page numbers count from 0, as the Page Streaming Extension has it.
+func opdsPageHandler(w http.ResponseWriter, r *http.Request) {
+	chapterID := chi.URLParam(r, "chapterID")
+	n, err := strconv.Atoi(chi.URLParam(r, "pageNumber"))
+	if err != nil || n < 0 {
+		http.Error(w, "Invalid page number", http.StatusBadRequest)
+		return
+	}
+	_, pages, err := catalogService.ChapterPages(r.Context(), chapterID, requestFilter(r))
+	if err != nil {
+		opdsError(w, r, err, "chapter "+chapterID)
+		return
+	}
+	if n >= len(pages) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	proxied := r.Clone(r.Context())
+	proxied.URL.RawQuery = url.Values{"url": {pages[n]}}.Encode()
+	imageProxyHandler(w, proxied)
+}
+
+// newCatalogFeed returns a feed of the requested URL with the links every
+// catalog page carries.
+func newCatalogFeed(r *http.Request, id, title, kind string) *opds.Feed {
+	site := siteURL(r)
+	return &opds.Feed{
+		ID:      id,
+		Title:   title,
+		Updated: time.Now(),
+		Author:  siteName,
+		Links: []opds.Link{
+			{Rel: opds.RelSelf, Href: site + r.URL.RequestURI(), Type: kind},
+			{Rel: opds.RelStart, Href: site + "/opds", Type: opds.NavigationType},
+			{Rel: opds.RelSearch, Href: site + "/opds/search.xml", Type: opds.OpenSearchType},
+		},
+	}
+}
+
+// paginate adds the previous and next page links of a paginated feed.
+func paginate(f *opds.Feed, r *http.Request, p catalog.Pagination, kind string) {
+	f.Total, f.ItemsPerPage, f.StartIndex = p.Total, p.Limit, (p.Page-1)*p.Limit+1
+	pageURL := func(page int) string {
+		u := *r.URL
+		q := u.Query()
+		q.Set("page", strconv.Itoa(page))
+		u.RawQuery = q.Encode()
+		return siteURL(r) + u.RequestURI()
+	}
+	if prev := p.PrevPage(); prev > 0 {
+		f.Links = append(f.Links, opds.Link{Rel: opds.RelPrevious, Href: pageURL(prev), Type: kind})
+	}
+	if next := p.NextPage(); next > 0 {
+		f.Links = append(f.Links, opds.Link{Rel: opds.RelNext, Href: pageURL(next), Type: kind})
+	}
+}
+
+// mangaEntry returns the navigation entry of a manga, leading to its
+// chapters.
+func mangaEntry(site string, manga mangadex.Manga, updated time.Time) opds.Entry {
+	entry := opds.Entry{
+		ID:       "urn:uuid:" + manga.ID,
+		Title:    manga.GetTitle(),
+		Updated:  updated,
+		Summary:  manga.GetDescription(),
+		Language: manga.Attributes.OriginalLanguage,
+		Links: []opds.Link{
+			{Rel: opds.RelSubsection, Href: site + "/opds/manga/" + url.PathEscape(manga.ID), Type: opds.AcquisitionType},
+			{Rel: opds.RelAlternate, Href: site + "/manga/" + url.PathEscape(manga.ID), Type: opds.HTMLType},
+		},
+	}
+	for _, a := range manga.Authors() {
+		entry.Authors = append(entry.Authors, a.Name())
+	}
+	for _, tag := range manga.Attributes.Tags {
+		entry.Categories = append(entry.Categories, tag.Name())
+	}
+	entry.Links = append(entry.Links, coverLinks(site, manga)...)
+	return entry
+}
+
+// coverLinks returns the image and thumbnail links of a manga's cover,
+// served through the image proxy.
+func coverLinks(site string, manga mangadex.Manga) []opds.Link {
+	if manga.Attributes.CoverURL == "" {
+		return nil
+	}
+	href := site + "/image-proxy?url=" + url.QueryEscape(manga.Attributes.CoverURL)
+	return []opds.Link{
+		{Rel: opds.RelImage, Href: href, Type: "image/jpeg"},
+		{Rel: opds.RelThumbnail, Href: href, Type: "image/jpeg"},
+	}
+}
+
+// serveCatalog writes f as an OPDS catalog document of the given kind.
+func serveCatalog(w http.ResponseWriter, r *http.Request, f *opds.Feed, kind string) {
+	body, err := f.Marshal()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding catalog", "err", err)
+		http.Error(w, "Error encoding catalog", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", kind+";charset=utf-8")
+	w.Write(body)
+}
+
+// opdsError writes the response for a failed catalog call. Unlike
+// catalogError it shows no content interstitial, which readers cannot pass.
+func opdsError(w http.ResponseWriter, r *http.Request, err error, what string) {
+	var restricted *catalog.RestrictedError
+	switch {
+	case errors.As(err, &restricted):
+		http.Error(w, "This manga is restricted", http.StatusForbidden)
+	case errors.Is(err, mangadex.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	default:
+		slog.ErrorContext(r.Context(), "Error fetching "+what, "err", err)
+		http.Error(w, "Error fetching "+what, http.StatusInternalServerError)
+	}
+}
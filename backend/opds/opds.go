@@ -0,0 +1,219 @@
+// Package opds writes OPDS 1.2 catalog feeds, including the links of the
+// OPDS Page Streaming Extension (PSE) that let readers fetch chapters page
+// by page.
+package opds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+// Media types of catalog documents.
+const (
+	NavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OpenSearchType  = "application/opensearchdescription+xml"
+	HTMLType        = "text/html"
+)
+
+// Link relations used by catalogs.
+const (
+	RelSelf        = "self"
+	RelStart       = "start"
+	RelUp          = "up"
+	RelNext        = "next"
+	RelPrevious    = "previous"
+	RelSearch      = "search"
+	RelSubsection  = "subsection"
+	RelAlternate   = "alternate"
+	RelPopular     = "http://opds-spec.org/sort/popular"
+	RelNew         = "http://opds-spec.org/sort/new"
+	RelShelf       = "http://opds-spec.org/shelf"
+	RelImage       = "http://opds-spec.org/image"
+	RelThumbnail   = "http://opds-spec.org/image/thumbnail"
+	RelAcquisition = "http://opds-spec.org/acquisition"
+	RelStream      = "http://vaemendis.net/opds-pse/stream" // PSE page stream
+)
+
+// Feed is a navigation or acquisition feed.
+type Feed struct {
+	ID      string
+	Title   string
+	Updated time.Time
+	Author  string
+	Links   []Link
+	Entries []Entry
+	// Total is the number of entries across all pages, when paginated.
+	Total        int
+	ItemsPerPage int
+	StartIndex   int // 1-based index of the first entry of this page
+}
+
+// Entry is a manga, a chapter or a link to another feed.
+type Entry struct {
+	ID         string
+	Title      string
+	Updated    time.Time
+	Published  time.Time
+	Authors    []string
+	Summary    string
+	Language   string
+	Categories []string
+	Links      []Link
+}
+
+// Link points from a feed or entry to another resource.
+type Link struct {
+	Rel   string
+	Href  string // a URL template with {pageNumber} for page streams
+	Type  string
+	Title string
+	Count int // number of pages of a page stream
+}
+
+type xmlFeed struct {
+	XMLName      xml.Name   `xml:"feed"`
+	Xmlns        string     `xml:"xmlns,attr"`
+	XmlnsOPDS    string     `xml:"xmlns:opds,attr"`
+	XmlnsPSE     string     `xml:"xmlns:pse,attr"`
+	XmlnsSearch  string     `xml:"xmlns:opensearch,attr"`
+	XmlnsDC      string     `xml:"xmlns:dc,attr"`
+	ID           string     `xml:"id"`
+	Title        string     `xml:"title"`
+	Updated      string     `xml:"updated"`
+	Author       *xmlPerson `xml:"author,omitempty"`
+	Total        int        `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int        `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   int        `xml:"opensearch:startIndex,omitempty"`
+	Links        []xmlLink  `xml:"link"`
+	Entries      []xmlEntry `xml:"entry"`
+}
+
+type xmlEntry struct {
+	ID         string        `xml:"id"`
+	Title      string        `xml:"title"`
+	Updated    string        `xml:"updated"`
+	Published  string        `xml:"published,omitempty"`
+	Authors    []xmlPerson   `xml:"author"`
+	Language   string        `xml:"dc:language,omitempty"`
+	Categories []xmlCategory `xml:"category"`
+	Summary    *xmlText      `xml:"summary"`
+	Links      []xmlLink     `xml:"link"`
+}
+
+type xmlPerson struct {
+	Name string `xml:"name"`
+}
+
+type xmlCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type xmlText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+	Count int    `xml:"pse:count,attr,omitempty"`
+}
+
+// Marshal encodes the feed as an Atom document.
+func (f *Feed) Marshal() ([]byte, error) {
+	doc := xmlFeed{
+		Xmlns:        "http://www.w3.org/2005/Atom",
+		XmlnsOPDS:    "http://opds-spec.org/2010/catalog",
+		XmlnsPSE:     "http://vaemendis.net/opds-pse/ns",
+		XmlnsSearch:  "http://a9.com/-/spec/opensearch/1.1/",
+		XmlnsDC:      "http://purl.org/dc/terms/",
+		ID:           f.ID,
+		Title:        f.Title,
+		Updated:      date(f.Updated),
+		Total:        f.Total,
+		ItemsPerPage: f.ItemsPerPage,
+		StartIndex:   f.StartIndex,
+		Links:        xmlLinks(f.Links),
+	}
+	if f.Author != "" {
+		doc.Author = &xmlPerson{Name: f.Author}
+	}
+	for _, e := range f.Entries {
+		entry := xmlEntry{
+			ID:       e.ID,
+			Title:    e.Title,
+			Updated:  date(e.Updated),
+			Language: e.Language,
+			Links:    xmlLinks(e.Links),
+		}
+		if !e.Published.IsZero() {
+			entry.Published = date(e.Published)
+		}
+		for _, name := range e.Authors {
+			entry.Authors = append(entry.Authors, xmlPerson{Name: name})
+		}
+		for _, c := range e.Categories {
+			entry.Categories = append(entry.Categories, xmlCategory{Term: c, Label: c})
+		}
+		if e.Summary != "" {
+			entry.Summary = &xmlText{Type: "text", Value: e.Summary}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return encode(doc)
+}
+
+func xmlLinks(links []Link) []xmlLink {
+	out := make([]xmlLink, len(links))
+	for i, l := range links {
+		out[i] = xmlLink{Rel: l.Rel, Href: l.Href, Type: l.Type, Title: l.Title, Count: l.Count}
+	}
+	return out
+}
+
+func date(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+type openSearch struct {
+	XMLName        xml.Name      `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	ShortName      string        `xml:"ShortName"`
+	Description    string        `xml:"Description"`
+	InputEncoding  string        `xml:"InputEncoding"`
+	OutputEncoding string        `xml:"OutputEncoding"`
+	URL            openSearchURL `xml:"Url"`
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// OpenSearchDescription describes how to search the catalog: template is
+// the URL of the results feed with {searchTerms} in place of the query.
+func OpenSearchDescription(shortName, description, template string) ([]byte, error) {
+	return encode(openSearch{
+		ShortName:      shortName,
+		Description:    description,
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		URL:            openSearchURL{Type: NavigationType, Template: template},
+	})
+}
+
+func encode(doc any) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,212 @@
+// Package openapi builds OpenAPI 3 documents from Go types and validates
+// JSON values against the generated schemas.
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI specification version documents are written in.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Server is a base URL the paths are relative to.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem holds the operations available on a path.
+type PathItem struct {
+	Get *Operation `json:"get,omitempty"`
+}
+
+// Operation describes a single endpoint.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path or query
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Response describes one response of an operation.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a response body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the named schemas referenced by the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of the OpenAPI schema object the generator emits.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+}
+
+// New returns an empty document.
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Version: version},
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+}
+
+// AddGet registers a GET operation whose success response is the JSON
+// encoding of successType and whose error responses are errorType.
+func (d *Document) AddGet(path string, op *Operation, successType, errorType reflect.Type, errorStatuses ...string) {
+	op.Responses = map[string]*Response{
+		"200": {
+			Description: "OK",
+			Content:     map[string]*MediaType{"application/json": {Schema: d.SchemaFor(successType)}},
+		},
+	}
+	for _, status := range errorStatuses {
+		op.Responses[status] = &Response{
+			Description: "Error",
+			Content:     map[string]*MediaType{"application/json": {Schema: d.SchemaFor(errorType)}},
+		}
+	}
+	d.Paths[path] = &PathItem{Get: op}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// SchemaFor returns the schema of the JSON encoding of t. Named struct
+// types are added to the document's components and referenced.
+func (d *Document) SchemaFor(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Pointer:
+		s := d.SchemaFor(t.Elem())
+		if s.Ref != "" {
+			// A $ref cannot carry siblings in OpenAPI 3.0; nullable
+			// references are rare enough to inline instead.
+			s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+			copied := *s
+			s = &copied
+		}
+		s.Nullable = true
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.SchemaFor(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.SchemaFor(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate.
+			d.Components.Schemas[name] = &Schema{Type: "object"}
+			d.Components.Schemas[name] = d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	// Interfaces and anything else accept any JSON value.
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, omitempty, skip := jsonField(field)
+		if skip {
+			continue
+		}
+		s.Properties[name] = d.SchemaFor(field.Type)
+		if !omitempty {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// jsonField reports the JSON name of a struct field and whether it is
+// omitted when empty or skipped entirely.
+func jsonField(field reflect.StructField) (name string, omitempty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, false
+}
+
+var packagePath = regexp.MustCompile(`[\w./-]*\.`)
+
+// schemaName derives a component name from a Go type name, flattening
+// generic instantiations: ListResponse[pkg.Manga] becomes ListResponse_Manga
+// and ItemResponse[[]pkg.Tag] becomes ItemResponse_ListOfTag.
+func schemaName(t reflect.Type) string {
+	name := packagePath.ReplaceAllString(t.Name(), "")
+	name = strings.NewReplacer("[]", "ListOf", "[", "_", "]", "", ",", "_", "*", "", " ", "").Replace(name)
+	return name
+}
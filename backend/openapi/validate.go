@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidateResponse checks a JSON response body against the schema the
+// document declares for the given path template and status code.
+func (d *Document) ValidateResponse(path string, status int, body []byte) error {
+	item, ok := d.Paths[path]
+	if !ok || item.Get == nil {
+		return fmt.Errorf("path %s is not in the document", path)
+	}
+	resp, ok := item.Get.Responses[fmt.Sprint(status)]
+	if !ok {
+		return fmt.Errorf("%s: status %d is not documented", path, status)
+	}
+	media, ok := resp.Content["application/json"]
+	if !ok {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s: invalid JSON: %w", path, err)
+	}
+	return d.Validate(media.Schema, value)
+}
+
+// Validate checks a decoded JSON value against a schema. Objects may not
+// carry properties the schema does not declare, so fields added to a
+// response without updating the document are reported too.
+func (d *Document) Validate(s *Schema, value any) error {
+	return d.validate(s, value, "$")
+}
+
+func (d *Document) validate(s *Schema, value any, at string) error {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		ref, ok := d.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("%s: unresolved reference %s", at, s.Ref)
+		}
+		return d.validate(ref, value, at)
+	}
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(at, s.Type, value)
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return typeError(at, s.Type, value)
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("%s: %v is not an integer", at, n)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return typeError(at, s.Type, value)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, str)
+			}
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %v", at, str, s.Enum)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return typeError(at, s.Type, value)
+		}
+		for i, item := range items {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return typeError(at, s.Type, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prop, ok := s.Properties[key]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if prop == nil {
+				return fmt.Errorf("%s: undocumented property %q", at, key)
+			}
+			if err := d.validate(prop, obj[key], at+"."+key); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", at, s.Type)
+	}
+	return nil
+}
+
+func typeError(at, want string, value any) error {
+	return fmt.Errorf("%s: expected %s, got %T", at, want, value)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nithish-95/manga/backend/catalog"
+	"github.com/nithish-95/manga/backend/store"
+)
+
+// continueReadingCount is the number of manga in the home page's
+// "Continue reading" row.
+const continueReadingCount = 10
+
+// continueItem is a manga in the "Continue reading" row.
+type continueItem struct {
+	store.Progress
+	CoverURL string
+}
+
+// recordProgress notes that the visitor opened a chapter at page. It does
+// nothing for visitors who are not logged in.
+func recordProgress(r *http.Request, reader *catalog.ReaderPage, page int) {
+	user := currentUser(r)
+	if user == nil {
+		return
+	}
+	p := store.Progress{
+		MangaID:    reader.MangaID,
+		MangaTitle: reader.MangaTitle,
+		ChapterID:  reader.Chapter.ID,
+		Chapter:    reader.Chapter.Heading(),
+		Page:       page,
+		Pages:      len(reader.Pages),
+	}
+	if err := db.SaveProgress(user.ID, p); err != nil {
+		slog.WarnContext(r.Context(), "Error saving reading progress", "manga_id", p.MangaID, "err", err)
+	}
+}
+
+// saveProgressHandler records the page the reader has scrolled to. It is
+// posted by the reader page and answers 204.
+func saveProgressHandler(w http.ResponseWriter, r *http.Request) {
+	mangaID := chi.URLParam(r, "mangaID")
+	chapterID := chi.URLParam(r, "chapterID")
+	page, err := strconv.Atoi(r.PostFormValue("page"))
+	if err != nil || page < 1 {
+		http.Error(w, "Invalid page", http.StatusBadRequest)
+		return
+	}
+
+	user := currentUser(r)
+	p, err := db.Progress(user.ID, mangaID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && p.ChapterID != chapterID) {
+		// Only the chapter last opened is tracked; a stale tab reporting
+		// an older chapter must not move progress back.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading progress", "manga_id", mangaID, "err", err)
+		http.Error(w, "Error saving progress", http.StatusInternalServerError)
+		return
+	}
+
+	p.Page = min(page, max(p.Pages, 1))
+	if err := db.SaveProgress(user.ID, *p); err != nil {
+		slog.ErrorContext(r.Context(), "Error saving progress", "manga_id", mangaID, "err", err)
+		http.Error(w, "Error saving progress", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// continueReading returns the visitor's most recently read manga with their
+// covers, or nil for visitors who are not logged in.
+func continueReading(r *http.Request) []continueItem {
+	user := currentUser(r)
+	if user == nil {
+		return nil
+	}
+	progress, err := db.RecentProgress(user.ID, continueReadingCount)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error reading progress", "err", err)
+		return nil
+	}
+
+	ids := make([]string, len(progress))
+	for i, p := range progress {
+		ids[i] = p.MangaID
+	}
+	covers := make(map[string]string)
+	for _, manga := range catalogService.Mangas(r.Context(), ids, contentFilter(r)) {
+		covers[manga.ID] = manga.Attributes.CoverURL
+	}
+
+	var items []continueItem
+	for _, p := range progress {
+		// Manga missing here are no longer allowed for the visitor.
+		if cover, ok := covers[p.MangaID]; ok {
+			items = append(items, continueItem{Progress: p, CoverURL: cover})
+		}
+	}
+	return items
+}
+
+// mangaProgress returns where the visitor stopped reading a manga and which
+// of its chapters they have read. Both are empty for visitors who are not
+// logged in.
+func mangaProgress(r *http.Request, mangaID string) (*store.Progress, map[string]bool) {
+	user := currentUser(r)
+	if user == nil {
+		return nil, nil
+	}
+	progress, err := db.Progress(user.ID, mangaID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.WarnContext(r.Context(), "Error reading progress", "manga_id", mangaID, "err", err)
+	}
+	read, err := db.ReadChapters(user.ID, mangaID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error reading read chapters", "manga_id", mangaID, "err", err)
+	}
+	return progress, read
+}
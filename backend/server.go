@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/nithish-95/manga/backend/config"
+)
+
+var (
+	// background is cancelled when the server starts shutting down. Jobs
+	// started with goBackground should return once it is done.
+	background, stopBackground = context.WithCancel(context.Background())
+	backgroundJobs             sync.WaitGroup
+
+	shutdownMu    sync.Mutex
+	shutdownHooks []func(context.Context) error
+)
+
+// goBackground runs job in its own goroutine. Shutdown cancels ctx and waits
+// for job to return.
+func goBackground(job func(ctx context.Context)) {
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		job(background)
+	}()
+}
+
+// onShutdown registers hook to run once requests and background jobs have
+// drained, for flushing caches and closing stores. Hooks run in reverse
+// order of registration.
+func onShutdown(hook func(ctx context.Context) error) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownHooks = append(shutdownHooks, hook)
+}
+
+// runServer serves handler until SIGINT or SIGTERM, then shuts down
+// gracefully within the configured timeout. It returns an error if the
+// server cannot listen or does not shut down cleanly.
+func runServer(cfg *config.Config, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:         cfg.Listen,
+		Handler:      handler,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+	slog.Info("Starting server", "addr", ln.Addr().String())
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process immediately
+
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("draining requests: %w", err))
+	}
+
+	stopBackground()
+	jobsDone := make(chan struct{})
+	go func() {
+		backgroundJobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-shutdownCtx.Done():
+		errs = append(errs, fmt.Errorf("waiting for background jobs: %w", shutdownCtx.Err()))
+	}
+
+	shutdownMu.Lock()
+	hooks := shutdownHooks
+	shutdownMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
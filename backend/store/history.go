@@ -0,0 +1,118 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// HistoryEntry is a chapter a user opened.
+type HistoryEntry struct {
+	ID         string    `json:"-"` // key in the history bucket, sorts by time
+	MangaID    string    `json:"mangaId"`
+	MangaTitle string    `json:"mangaTitle"`
+	ChapterID  string    `json:"chapterId"`
+	Chapter    string    `json:"chapter"`
+	Opened     time.Time `json:"opened"`
+}
+
+// historyKey orders entries by the time they were opened.
+func historyKey(t time.Time) string {
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
+
+// AddHistory records that a user opened a chapter. Opening the chapter
+// that is already the latest entry only moves that entry's time forward.
+func (s *Store) AddHistory(userID string, e HistoryEntry) error {
+	e.Opened = time.Now().UTC()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(userID))
+		if err != nil {
+			return err
+		}
+		if k, v := b.Cursor().Last(); k != nil {
+			var last HistoryEntry
+			if err := json.Unmarshal(v, &last); err != nil {
+				return err
+			}
+			if last.ChapterID == e.ChapterID {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return put(b, historyKey(e.Opened), e)
+	})
+}
+
+// History returns a page of a user's history, newest first, along with the
+// total number of entries.
+func (s *Store) History(userID string, offset, limit int) ([]HistoryEntry, int, error) {
+	var entries []HistoryEntry
+	var total int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		total = b.Stats().KeyN
+		c := b.Cursor()
+		i := 0
+		for k, v := c.Last(); k != nil && len(entries) < limit; k, v = c.Prev() {
+			if i++; i <= offset {
+				continue
+			}
+			var e HistoryEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			e.ID = string(k)
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, total, err
+}
+
+// DeleteHistory removes entries from a user's history. Missing entries are
+// ignored.
+func (s *Store) DeleteHistory(userID string, ids ...string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		for _, id := range ids {
+			if err := b.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClearHistory removes a user's whole history.
+func (s *Store) ClearHistory(userID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		history := tx.Bucket(historyBucket)
+		if history.Bucket([]byte(userID)) == nil {
+			return nil
+		}
+		return history.DeleteBucket([]byte(userID))
+	})
+}
+
+// SetHistoryPaused turns history recording off or back on for a user.
+func (s *Store) SetHistoryPaused(userID string, paused bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		var user User
+		if err := get(b, userID, &user); err != nil {
+			return err
+		}
+		user.HistoryPaused = paused
+		return put(b, userID, user)
+	})
+}
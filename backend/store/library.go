@@ -0,0 +1,120 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ReadingStatus is the shelf a manga sits on in a user's library.
+type ReadingStatus string
+
+const (
+	StatusReading    ReadingStatus = "reading"
+	StatusPlanToRead ReadingStatus = "plan_to_read"
+	StatusCompleted  ReadingStatus = "completed"
+	StatusOnHold     ReadingStatus = "on_hold"
+	StatusDropped    ReadingStatus = "dropped"
+	StatusReReading  ReadingStatus = "re_reading"
+)
+
+// ReadingStatuses lists every status in the order shelves are shown.
+var ReadingStatuses = []ReadingStatus{StatusReading, StatusReReading, StatusPlanToRead, StatusOnHold, StatusCompleted, StatusDropped}
+
+var statusLabels = map[ReadingStatus]string{
+	StatusReading:    "Reading",
+	StatusPlanToRead: "Plan to read",
+	StatusCompleted:  "Completed",
+	StatusOnHold:     "On hold",
+	StatusDropped:    "Dropped",
+	StatusReReading:  "Re-reading",
+}
+
+// Label returns the status for display.
+func (s ReadingStatus) Label() string {
+	return statusLabels[s]
+}
+
+// Valid reports whether s is one of ReadingStatuses.
+func (s ReadingStatus) Valid() bool {
+	return slices.Contains(ReadingStatuses, s)
+}
+
+// LibraryEntry is a manga in a user's library.
+type LibraryEntry struct {
+	MangaID string        `json:"mangaId"`
+	Status  ReadingStatus `json:"status"`
+	Added   time.Time     `json:"added"`
+	Updated time.Time     `json:"updated"`
+}
+
+// SetLibraryStatus adds a manga to a user's library or moves it to another
+// shelf.
+func (s *Store) SetLibraryStatus(userID, mangaID string, status ReadingStatus) error {
+	now := time.Now().UTC()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(libraryBucket).CreateBucketIfNotExists([]byte(userID))
+		if err != nil {
+			return err
+		}
+		entry := LibraryEntry{MangaID: mangaID, Added: now}
+		if err := get(b, mangaID, &entry); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		entry.Status = status
+		entry.Updated = now
+		return put(b, mangaID, entry)
+	})
+}
+
+// RemoveFromLibrary removes a manga from a user's library. Removing a manga
+// that is not there is not an error.
+func (s *Store) RemoveFromLibrary(userID, mangaID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(libraryBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(mangaID))
+	})
+}
+
+// LibraryEntry returns a manga's entry in a user's library.
+func (s *Store) LibraryEntry(userID, mangaID string) (*LibraryEntry, error) {
+	var entry LibraryEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(libraryBucket).Bucket([]byte(userID))
+		if b == nil {
+			return ErrNotFound
+		}
+		return get(b, mangaID, &entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Library returns a user's library, most recently updated first.
+func (s *Store) Library(userID string) ([]LibraryEntry, error) {
+	var entries []LibraryEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(libraryBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var entry LibraryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	slices.SortFunc(entries, func(a, b LibraryEntry) int { return b.Updated.Compare(a.Updated) })
+	return entries, err
+}
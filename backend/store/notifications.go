@@ -0,0 +1,321 @@
+package store
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ChannelKind is where a notification channel delivers to.
+type ChannelKind string
+
+const (
+	ChannelWebhook ChannelKind = "webhook" // JSON POST signed with the channel secret
+	ChannelDiscord ChannelKind = "discord" // Discord-compatible webhook
+	ChannelEmail   ChannelKind = "email"   // email digest
+)
+
+// ChannelKinds lists every kind in the order they are offered.
+var ChannelKinds = []ChannelKind{ChannelWebhook, ChannelDiscord, ChannelEmail}
+
+var channelLabels = map[ChannelKind]string{
+	ChannelWebhook: "Webhook",
+	ChannelDiscord: "Discord",
+	ChannelEmail:   "Email digest",
+}
+
+// Label returns the kind for display.
+func (k ChannelKind) Label() string {
+	return channelLabels[k]
+}
+
+// Valid reports whether k is one of ChannelKinds.
+func (k ChannelKind) Valid() bool {
+	return slices.Contains(ChannelKinds, k)
+}
+
+// Channel is a place a user is notified of new chapters.
+type Channel struct {
+	ID      string      `json:"id"`
+	Kind    ChannelKind `json:"kind"`
+	Target  string      `json:"target"`           // webhook URL or email address
+	Secret  string      `json:"secret,omitempty"` // signs webhook payloads
+	Created time.Time   `json:"created"`
+
+	// Email channels are used once the link with ConfirmToken that was
+	// sent to the address is opened, so that users cannot have mail sent
+	// to addresses that are not theirs.
+	ConfirmToken string `json:"confirmToken,omitempty"`
+	Confirmed    bool   `json:"confirmed,omitempty"`
+}
+
+// Active reports whether notifications are sent to the channel.
+func (c Channel) Active() bool {
+	return c.Kind != ChannelEmail || c.Confirmed
+}
+
+// Delivery is a notification waiting to be sent to a channel.
+type Delivery struct {
+	ID        string    `json:"-"` // key in the deliveries bucket, sorts by due time
+	UserID    string    `json:"userId"`
+	ChannelID string    `json:"channelId"`
+	Updates   []Update  `json:"updates"`
+	Due       time.Time `json:"due"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError,omitempty"`
+	Sending   bool      `json:"sending,omitempty"` // claimed by ClaimDelivery
+}
+
+func deliveryKey(due time.Time) string {
+	return historyKey(due) + "/" + newToken(6)
+}
+
+// AddChannel adds a notification channel for a user. Webhook channels get a
+// random secret, and email channels a token to confirm the address with.
+func (s *Store) AddChannel(userID string, kind ChannelKind, target string) (*Channel, error) {
+	ch := &Channel{
+		ID:      newToken(9),
+		Kind:    kind,
+		Target:  target,
+		Created: time.Now().UTC(),
+	}
+	switch kind {
+	case ChannelWebhook:
+		ch.Secret = newToken(24)
+	case ChannelEmail:
+		ch.ConfirmToken = newToken(24)
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(channelsBucket).CreateBucketIfNotExists([]byte(userID))
+		if err != nil {
+			return err
+		}
+		return put(b, ch.ID, ch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// Channel returns one of a user's notification channels.
+func (s *Store) Channel(userID, id string) (*Channel, error) {
+	var ch Channel
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(channelsBucket).Bucket([]byte(userID))
+		if b == nil {
+			return ErrNotFound
+		}
+		return get(b, id, &ch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// Channels returns a user's notification channels, oldest first.
+func (s *Store) Channels(userID string) ([]Channel, error) {
+	var channels []Channel
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(channelsBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var ch Channel
+			if err := json.Unmarshal(v, &ch); err != nil {
+				return err
+			}
+			channels = append(channels, ch)
+			return nil
+		})
+	})
+	slices.SortFunc(channels, func(a, b Channel) int { return a.Created.Compare(b.Created) })
+	return channels, err
+}
+
+// ConfirmChannel marks an email channel as confirmed if token is the one
+// sent to its address, and returns ErrNotFound otherwise. Confirming a
+// channel again does nothing.
+func (s *Store) ConfirmChannel(userID, id, token string) (*Channel, error) {
+	var ch Channel
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(channelsBucket).Bucket([]byte(userID))
+		if b == nil {
+			return ErrNotFound
+		}
+		if err := get(b, id, &ch); err != nil {
+			return err
+		}
+		if ch.Confirmed {
+			return nil
+		}
+		if ch.ConfirmToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(ch.ConfirmToken)) != 1 {
+			return ErrNotFound
+		}
+		ch.Confirmed = true
+		ch.ConfirmToken = ""
+		return put(b, id, ch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// DeleteChannel removes a notification channel. Deliveries still queued for
+// it are dropped when they come due.
+func (s *Store) DeleteChannel(userID, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(channelsBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// QueueUpdates queues updates for delivery to a channel at due. Updates for
+// a channel that already has a delivery waiting for its first attempt are
+// added to that delivery instead, which is how email digests collect
+// chapters. Deliveries being sent are left alone.
+func (s *Store) QueueUpdates(userID, channelID string, updates []Update, due time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(deliveriesBucket)
+		if index := tx.Bucket(deliveryIndexBucket).Bucket([]byte(userID)); index != nil {
+			prefix := []byte(channelID + "/")
+			c := index.Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				key := string(k[len(prefix):])
+				var d Delivery
+				if err := get(b, key, &d); err != nil {
+					return err
+				}
+				if d.Attempts == 0 && !d.Sending {
+					d.Updates = append(d.Updates, updates...)
+					return put(b, key, d)
+				}
+			}
+		}
+		d := Delivery{UserID: userID, ChannelID: channelID, Updates: updates, Due: due.UTC()}
+		return putDelivery(tx, deliveryKey(d.Due), d)
+	})
+}
+
+// putDelivery stores a new delivery and indexes it under its user and
+// channel.
+func putDelivery(tx *bolt.Tx, key string, d Delivery) error {
+	if err := put(tx.Bucket(deliveriesBucket), key, d); err != nil {
+		return err
+	}
+	index, err := tx.Bucket(deliveryIndexBucket).CreateBucketIfNotExists([]byte(d.UserID))
+	if err != nil {
+		return err
+	}
+	return index.Put([]byte(d.ChannelID+"/"+key), nil)
+}
+
+// deleteDelivery removes a delivery and its index entry.
+func deleteDelivery(tx *bolt.Tx, key string) error {
+	b := tx.Bucket(deliveriesBucket)
+	var d Delivery
+	if err := get(b, key, &d); errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if index := tx.Bucket(deliveryIndexBucket).Bucket([]byte(d.UserID)); index != nil {
+		if err := index.Delete([]byte(d.ChannelID + "/" + key)); err != nil {
+			return err
+		}
+	}
+	return b.Delete([]byte(key))
+}
+
+// DueDeliveries returns up to limit deliveries due by now, most overdue
+// first.
+func (s *Store) DueDeliveries(now time.Time, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+	end := []byte(historyKey(now))
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(deliveriesBucket).Cursor()
+		for k, v := c.First(); k != nil && string(k[:len(end)]) <= string(end) && len(deliveries) < limit; k, v = c.Next() {
+			var d Delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			d.ID = string(k)
+			deliveries = append(deliveries, d)
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// ClaimDelivery marks a delivery as being sent and returns it as stored, so
+// that updates queued meanwhile go to a new delivery instead of one that
+// is removed once sent.
+func (s *Store) ClaimDelivery(id string) (*Delivery, error) {
+	var d Delivery
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(deliveriesBucket)
+		if err := get(b, id, &d); err != nil {
+			return err
+		}
+		d.Sending = true
+		return put(b, id, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.ID = id
+	return &d, nil
+}
+
+// RetryDelivery records a failed attempt at a claimed delivery and queues
+// it again at due.
+func (s *Store) RetryDelivery(d Delivery, due time.Time, cause error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteDelivery(tx, d.ID); err != nil {
+			return err
+		}
+		d.Attempts++
+		d.LastError = cause.Error()
+		d.Sending = false
+		d.Due = due.UTC()
+		return putDelivery(tx, deliveryKey(d.Due), d)
+	})
+}
+
+// DeleteDelivery removes a delivery from the queue once it was sent or
+// given up on.
+func (s *Store) DeleteDelivery(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteDelivery(tx, id)
+	})
+}
+
+// PendingDeliveries counts the deliveries queued for each of a user's
+// channels.
+func (s *Store) PendingDeliveries(userID string) (map[string]int, error) {
+	pending := make(map[string]int)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		index := tx.Bucket(deliveryIndexBucket).Bucket([]byte(userID))
+		if index == nil {
+			return nil
+		}
+		return index.ForEach(func(k, _ []byte) error {
+			channelID, _, _ := strings.Cut(string(k), "/")
+			pending[channelID]++
+			return nil
+		})
+	})
+	return pending, err
+}
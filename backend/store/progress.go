@@ -0,0 +1,123 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Progress is where a user stopped reading a manga.
+type Progress struct {
+	MangaID    string    `json:"mangaId"`
+	MangaTitle string    `json:"mangaTitle"`
+	ChapterID  string    `json:"chapterId"`
+	Chapter    string    `json:"chapter"` // heading such as "Vol. 1 Ch. 3"
+	Page       int       `json:"page"`    // 1-based
+	Pages      int       `json:"pages"`
+	Updated    time.Time `json:"updated"`
+}
+
+// Finished reports whether the last page of the chapter was reached.
+func (p Progress) Finished() bool {
+	return p.Pages > 0 && p.Page >= p.Pages
+}
+
+// SaveProgress records where a user is in a manga. Reaching the last page
+// also marks the chapter read.
+func (s *Store) SaveProgress(userID string, p Progress) error {
+	p.Updated = time.Now().UTC()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(progressBucket).CreateBucketIfNotExists([]byte(userID))
+		if err != nil {
+			return err
+		}
+		if err := put(b, p.MangaID, p); err != nil {
+			return err
+		}
+		if p.Finished() {
+			return markRead(tx, userID, p.MangaID, p.ChapterID, true)
+		}
+		return nil
+	})
+}
+
+// Progress returns where a user stopped reading a manga.
+func (s *Store) Progress(userID, mangaID string) (*Progress, error) {
+	var p Progress
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(progressBucket).Bucket([]byte(userID))
+		if b == nil {
+			return ErrNotFound
+		}
+		return get(b, mangaID, &p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RecentProgress returns up to limit of a user's manga, most recently read
+// first.
+func (s *Store) RecentProgress(userID string, limit int) ([]Progress, error) {
+	var progress []Progress
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(progressBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var p Progress
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			progress = append(progress, p)
+			return nil
+		})
+	})
+	slices.SortFunc(progress, func(a, b Progress) int { return b.Updated.Compare(a.Updated) })
+	if len(progress) > limit {
+		progress = progress[:limit]
+	}
+	return progress, err
+}
+
+// MarkRead marks a chapter read or unread.
+func (s *Store) MarkRead(userID, mangaID, chapterID string, read bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return markRead(tx, userID, mangaID, chapterID, read)
+	})
+}
+
+func markRead(tx *bolt.Tx, userID, mangaID, chapterID string, read bool) error {
+	b, err := tx.Bucket(readBucket).CreateBucketIfNotExists([]byte(userID))
+	if err != nil {
+		return err
+	}
+	key := []byte(mangaID + "/" + chapterID)
+	if !read {
+		return b.Delete(key)
+	}
+	return put(b, string(key), time.Now().UTC())
+}
+
+// ReadChapters returns the IDs of the chapters of a manga a user has read.
+func (s *Store) ReadChapters(userID, mangaID string) (map[string]bool, error) {
+	read := make(map[string]bool)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(readBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		prefix := []byte(mangaID + "/")
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			read[string(k[len(prefix):])] = true
+		}
+		return nil
+	})
+	return read, err
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Session is a logged-in browser. Only a hash of its token is stored, so
+// the database cannot be used to hijack sessions.
+type Session struct {
+	UserID  string    `json:"userId"`
+	Expires time.Time `json:"expires"`
+}
+
+func sessionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession starts a session for a user that lasts ttl and returns its
+// token.
+func (s *Store) CreateSession(userID string, ttl time.Duration) (string, error) {
+	token := newToken(32)
+	session := Session{UserID: userID, Expires: time.Now().Add(ttl).UTC()}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(sessionsBucket), sessionKey(token), session)
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Session returns the session with the given token. Expired sessions are
+// reported as ErrNotFound.
+func (s *Store) Session(token string) (*Session, error) {
+	var session Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(sessionsBucket), sessionKey(token), &session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(session.Expires) {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// DeleteSession ends a session. Deleting a missing session is not an error.
+func (s *Store) DeleteSession(token string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(sessionKey(token)))
+	})
+}
+
+// DeleteExpiredSessions removes sessions that have expired and returns how
+// many there were.
+func (s *Store) DeleteExpiredSessions() (int, error) {
+	now := time.Now()
+	var n int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var session Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			if now.After(session.Expires) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	return n, err
+}
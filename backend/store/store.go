@@ -0,0 +1,101 @@
+// Package store keeps user accounts and their data in an embedded bbolt
+// database file.
+package store
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned when a record does not exist.
+var ErrNotFound = errors.New("not found")
+
+var (
+	usersBucket         = []byte("users")          // user ID -> User
+	usernamesBucket     = []byte("usernames")      // lower-cased username -> user ID
+	sessionsBucket      = []byte("sessions")       // hashed session token -> Session
+	libraryBucket       = []byte("library")        // user ID -> bucket of manga ID -> LibraryEntry
+	progressBucket      = []byte("progress")       // user ID -> bucket of manga ID -> Progress
+	readBucket          = []byte("read")           // user ID -> bucket of "mangaID/chapterID" -> time read
+	historyBucket       = []byte("history")        // user ID -> bucket of time opened -> HistoryEntry
+	feedsBucket         = []byte("feeds")          // manga ID -> FeedState
+	updatesBucket       = []byte("updates")        // user ID -> bucket of "publishTime/chapterID" -> Update
+	channelsBucket      = []byte("channels")       // user ID -> bucket of channel ID -> Channel
+	deliveriesBucket    = []byte("deliveries")     // "dueTime/random" -> Delivery
+	deliveryIndexBucket = []byte("delivery_index") // user ID -> bucket of "channelID/dueTime/random" -> nothing
+	feedTokensBucket    = []byte("feed_tokens")    // library feed token -> user ID
+)
+
+// buckets are created when the database is opened.
+var buckets = [][]byte{
+	usersBucket, usernamesBucket, sessionsBucket, libraryBucket, progressBucket,
+	readBucket, historyBucket, feedsBucket, updatesBucket, channelsBucket, deliveriesBucket,
+	deliveryIndexBucket, feedTokensBucket,
+}
+
+// Store is an open database. It is safe for concurrent use.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the database at path, creating it if needed.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening database %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating buckets in %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Path returns the database file name.
+func (s *Store) Path() string {
+	return s.db.Path()
+}
+
+// get decodes the JSON value stored under key into v.
+func get(b *bolt.Bucket, key string, v any) error {
+	data := b.Get([]byte(key))
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+// put stores v under key as JSON.
+func put(b *bolt.Bucket, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), data)
+}
+
+// newToken returns a random URL-safe token of n random bytes.
+func newToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
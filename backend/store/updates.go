@@ -0,0 +1,216 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// maxUpdatesPerUser bounds each user's updates; the oldest are dropped.
+const maxUpdatesPerUser = 1000
+
+// Update is a new chapter of a manga in a user's library.
+type Update struct {
+	ID         string    `json:"-"` // key in the updates bucket, sorts by publish time
+	MangaID    string    `json:"mangaId"`
+	MangaTitle string    `json:"mangaTitle"`
+	ChapterID  string    `json:"chapterId"`
+	Chapter    string    `json:"chapter"`
+	Language   string    `json:"language,omitempty"`
+	Published  time.Time `json:"published"`
+	Found      time.Time `json:"found"`
+	Read       bool      `json:"read"`
+}
+
+func updateKey(u Update) string {
+	return historyKey(u.Published) + "/" + u.ChapterID
+}
+
+// FeedState is how far the update checker has read a manga's chapter feed.
+type FeedState struct {
+	Since   time.Time `json:"since"`   // first check; older chapters are not new
+	Checked time.Time `json:"checked"` // chapters published before this were seen
+}
+
+// FollowedManga returns the IDs of the users having each manga in their
+// library.
+func (s *Store) FollowedManga() (map[string][]string, error) {
+	followers := make(map[string][]string)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(libraryBucket).ForEachBucket(func(userID []byte) error {
+			return tx.Bucket(libraryBucket).Bucket(userID).ForEach(func(mangaID, _ []byte) error {
+				followers[string(mangaID)] = append(followers[string(mangaID)], string(userID))
+				return nil
+			})
+		})
+	})
+	return followers, err
+}
+
+// FeedState returns how far a manga's feed was checked.
+func (s *Store) FeedState(mangaID string) (*FeedState, error) {
+	var state FeedState
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(feedsBucket), mangaID, &state)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SetFeedState records how far a manga's feed was checked.
+func (s *Store) SetFeedState(mangaID string, state FeedState) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(feedsBucket), mangaID, state)
+	})
+}
+
+// AddUpdates gives each of the users the new chapters, skipping chapters a
+// user already has, and returns the updates each user did not have yet.
+func (s *Store) AddUpdates(userIDs []string, updates []Update) (map[string][]Update, error) {
+	added := make(map[string][]Update)
+	now := time.Now().UTC()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, userID := range userIDs {
+			b, err := tx.Bucket(updatesBucket).CreateBucketIfNotExists([]byte(userID))
+			if err != nil {
+				return err
+			}
+			for _, u := range updates {
+				u.ID = updateKey(u)
+				if b.Get([]byte(u.ID)) != nil {
+					continue
+				}
+				u.Found = now
+				if err := put(b, u.ID, u); err != nil {
+					return err
+				}
+				added[userID] = append(added[userID], u)
+			}
+			if err := prune(b, maxUpdatesPerUser); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// Updates returns a page of a user's updates, newest first, along with the
+// total number of updates.
+func (s *Store) Updates(userID string, offset, limit int) ([]Update, int, error) {
+	var updates []Update
+	var total int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(updatesBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		total = b.Stats().KeyN
+		c := b.Cursor()
+		i := 0
+		for k, v := c.Last(); k != nil && len(updates) < limit; k, v = c.Prev() {
+			if i++; i <= offset {
+				continue
+			}
+			var u Update
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			u.ID = string(k)
+			updates = append(updates, u)
+		}
+		return nil
+	})
+	return updates, total, err
+}
+
+// UnreadUpdates counts a user's unread updates per manga.
+func (s *Store) UnreadUpdates(userID string) (map[string]int, error) {
+	unread := make(map[string]int)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(updatesBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var u Update
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			if !u.Read {
+				unread[u.MangaID]++
+			}
+			return nil
+		})
+	})
+	return unread, err
+}
+
+// MarkUpdateRead marks the update for a chapter read, if the user has one.
+func (s *Store) MarkUpdateRead(userID, chapterID string) error {
+	suffix := []byte("/" + chapterID)
+	return s.markUpdatesRead(userID, func(k []byte) bool { return bytes.HasSuffix(k, suffix) })
+}
+
+// MarkAllUpdatesRead marks every update of a user read.
+func (s *Store) MarkAllUpdatesRead(userID string) error {
+	return s.markUpdatesRead(userID, func([]byte) bool { return true })
+}
+
+func (s *Store) markUpdatesRead(userID string, match func(key []byte) bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(updatesBucket).Bucket([]byte(userID))
+		if b == nil {
+			return nil
+		}
+		var changed []Update
+		err := b.ForEach(func(k, v []byte) error {
+			if !match(k) {
+				return nil
+			}
+			var u Update
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			if !u.Read {
+				u.Read, u.ID = true, string(k)
+				changed = append(changed, u)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, u := range changed {
+			if err := put(b, u.ID, u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// prune deletes the keys of b that sort before its newest keep keys.
+func prune(b *bolt.Bucket, keep int) error {
+	var old [][]byte
+	c := b.Cursor()
+	n := 0
+	for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+		if n++; n > keep {
+			old = append(old, append([]byte(nil), k...))
+		}
+	}
+	for _, k := range old {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrUsernameTaken is returned when registering a username that is in use,
+// compared case-insensitively.
+var ErrUsernameTaken = errors.New("username is taken")
+
+// User is a registered account.
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash []byte    `json:"passwordHash"`
+	Created      time.Time `json:"created"`
+
+	HistoryPaused bool   `json:"historyPaused"`
+	FeedToken     string `json:"feedToken,omitempty"` // secret in the user's library feed URL
+}
+
+// CreateUser registers a user with an already hashed password.
+func (s *Store) CreateUser(username string, passwordHash []byte) (*User, error) {
+	user := &User{
+		ID:           newToken(12),
+		Username:     username,
+		PasswordHash: passwordHash,
+		Created:      time.Now().UTC(),
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		names := tx.Bucket(usernamesBucket)
+		key := []byte(strings.ToLower(username))
+		if names.Get(key) != nil {
+			return ErrUsernameTaken
+		}
+		if err := names.Put(key, []byte(user.ID)); err != nil {
+			return err
+		}
+		return put(tx.Bucket(usersBucket), user.ID, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// User returns the user with the given ID.
+func (s *Store) User(id string) (*User, error) {
+	var user User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(usersBucket), id, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UserByName returns the user with the given username, compared
+// case-insensitively.
+func (s *Store) UserByName(username string) (*User, error) {
+	var user User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(usernamesBucket).Get([]byte(strings.ToLower(username)))
+		if id == nil {
+			return ErrNotFound
+		}
+		return get(tx.Bucket(usersBucket), string(id), &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LibraryFeedToken returns the token of a user's library feed, creating one
+// the first time. With reset, the old token stops working and a new one is
+// returned.
+func (s *Store) LibraryFeedToken(userID string, reset bool) (string, error) {
+	var token string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var user User
+		if err := get(tx.Bucket(usersBucket), userID, &user); err != nil {
+			return err
+		}
+		if user.FeedToken != "" && !reset {
+			token = user.FeedToken
+			return nil
+		}
+		tokens := tx.Bucket(feedTokensBucket)
+		if user.FeedToken != "" {
+			if err := tokens.Delete([]byte(user.FeedToken)); err != nil {
+				return err
+			}
+		}
+		user.FeedToken = newToken(24)
+		if err := tokens.Put([]byte(user.FeedToken), []byte(userID)); err != nil {
+			return err
+		}
+		token = user.FeedToken
+		return put(tx.Bucket(usersBucket), userID, user)
+	})
+	return token, err
+}
+
+// UserByFeedToken returns the user whose library feed has the given token.
+func (s *Store) UserByFeedToken(token string) (*User, error) {
+	var user User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(feedTokensBucket).Get([]byte(token))
+		if id == nil {
+			return ErrNotFound
+		}
+		return get(tx.Bucket(usersBucket), string(id), &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
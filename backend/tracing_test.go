@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nithish-95/manga/backend/config"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpReceiver collects the spans exported to it over OTLP/HTTP.
+type otlpReceiver struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (rcv *otlpReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	var req collectorpb.ExportTraceServiceRequest
+	if err == nil {
+		err = proto.Unmarshal(body, &req)
+	}
+	if r.URL.Path != "/v1/traces" || err != nil {
+		http.Error(w, "bad export", http.StatusBadRequest)
+		return
+	}
+	rcv.mu.Lock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			rcv.spans = append(rcv.spans, ss.Spans...)
+		}
+	}
+	rcv.mu.Unlock()
+	resp, _ := proto.Marshal(&collectorpb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(resp)
+}
+
+// attr returns the value of a span attribute, or nil if it is not set.
+func attr(span *tracepb.Span, key string) any {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			switch v := kv.Value.Value.(type) {
+			case *commonpb.AnyValue_StringValue:
+				return v.StringValue
+			case *commonpb.AnyValue_IntValue:
+				return v.IntValue
+			}
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+// TestTracingExportsSpans serves an API request with tracing pointed at a
+// stand-in collector and checks that the server span and the upstream
+// calls it made, including a retried one, arrive in the same trace.
+func TestTracingExportsSpans(t *testing.T) {
+	fakeMangaDex(t)
+	receiver := &otlpReceiver{}
+	collector := httptest.NewServer(receiver)
+	defer collector.Close()
+
+	cfg := config.Default()
+	cfg.Tracing.Endpoint = collector.URL
+	if err := setupTracing(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	provider := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	router := chi.NewRouter()
+	router.Use(traceRequests)
+	router.Mount("/api/v1", apiRouter())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/manga/flaky", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	var server *tracepb.Span
+	for _, span := range receiver.spans {
+		if span.Kind == tracepb.Span_SPAN_KIND_SERVER {
+			server = span
+		}
+	}
+	if server == nil {
+		t.Fatalf("no server span among %d exported", len(receiver.spans))
+	}
+	if server.Name != "GET /api/v1/manga/{mangaID}" {
+		t.Errorf("server span name = %q", server.Name)
+	}
+	if got := attr(server, "http.response.status_code"); got != int64(http.StatusOK) {
+		t.Errorf("server span status code = %v, want 200", got)
+	}
+
+	// The manga is unavailable at first, so it is fetched twice.
+	var attempts []*tracepb.Span
+	for _, span := range receiver.spans {
+		if span.Kind != tracepb.Span_SPAN_KIND_CLIENT {
+			continue
+		}
+		if string(span.TraceId) != string(server.TraceId) {
+			t.Errorf("upstream span %q is not in the request's trace", span.Name)
+		}
+		if span.Name == "GET /manga/{id}" {
+			attempts = append(attempts, span)
+		}
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("got %d upstream spans for the manga, want 2", len(attempts))
+	}
+	for i, want := range []struct {
+		status int64
+		resend any
+	}{{http.StatusServiceUnavailable, nil}, {http.StatusOK, int64(1)}} {
+		span := attempts[i]
+		if got := attr(span, "url.template"); got != "/manga/{id}" {
+			t.Errorf("attempt %d: url.template = %v", i, got)
+		}
+		if got := attr(span, "http.response.status_code"); got != want.status {
+			t.Errorf("attempt %d: status code = %v, want %d", i, got, want.status)
+		}
+		if got := attr(span, "http.request.resend_count"); got != want.resend {
+			t.Errorf("attempt %d: resend count = %v, want %v", i, got, want.resend)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nithish-95/manga/backend/catalog"
+	"github.com/nithish-95/manga/backend/mangadex"
+	"github.com/nithish-95/manga/backend/metrics"
+	"github.com/nithish-95/manga/backend/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	// updatesPageSize is the number of chapters on a page of /updates.
+	updatesPageSize = 50
+	// feedOverlap is how far before the last check each feed is read again,
+	// so chapters published while a check ran are not missed. Chapters
+	// seen twice are ignored.
+	feedOverlap = 5 * time.Minute
+	// updateCheckPace is the time between the feeds of one round. It
+	// keeps the checker polite when the API rate limit is off, and leaves
+	// most of the limit to readers when it is on.
+	updateCheckPace = time.Second
+)
+
+// updateDay is the new chapters published on one day.
+type updateDay struct {
+	Label   string
+	Updates []store.Update
+}
+
+// startUpdateChecker checks every manga in any user's library for new
+// chapters now and then every interval, until shutdown. A zero interval
+// disables it.
+func startUpdateChecker(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			checkUpdates(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// checkUpdates runs one round of the update checker. Feeds are fetched one
+// at a time, updateCheckPace apart, through the shared MangaDex client, so
+// the checker also respects the API rate limit along with page requests.
+func checkUpdates(ctx context.Context) {
+	followers, err := db.FollowedManga()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing followed manga", "err", err)
+		return
+	}
+
+	ctx, span := tracer.Start(ctx, "update check")
+	defer span.End()
+	span.SetAttributes(attribute.Int("manga.count", len(followers)))
+
+	// The chapters each user got this round, notified together.
+	added := make(map[string][]store.Update)
+	found := 0
+	pace := time.NewTicker(updateCheckPace)
+	defer pace.Stop()
+	first := true
+	for mangaID, userIDs := range followers {
+		if !first {
+			select {
+			case <-ctx.Done():
+			case <-pace.C:
+			}
+		}
+		first = false
+		if ctx.Err() != nil {
+			break
+		}
+		updates, err := checkManga(ctx, mangaID, userIDs)
+		if err != nil {
+			metrics.UpdateChecks.WithLabelValues("error").Inc()
+			span.RecordError(err)
+			slog.WarnContext(ctx, "Error checking manga for new chapters", "manga_id", mangaID, "err", err)
+			continue
+		}
+		metrics.UpdateChecks.WithLabelValues("ok").Inc()
+		for userID, userUpdates := range updates {
+			added[userID] = append(added[userID], userUpdates...)
+		}
+		found += countChapters(updates)
+	}
+	if found > 0 {
+		slog.InfoContext(ctx, "Found new chapters", "count", found)
+		queueNotifications(ctx, added)
+	}
+	span.SetAttributes(attribute.Int("chapters.new", found))
+	if ctx.Err() != nil {
+		span.SetStatus(codes.Error, "cancelled")
+	}
+}
+
+// checkManga fetches the chapters of a manga published since its last
+// check and gives them to the users following it. It returns the chapters
+// each user did not have yet. The first check of a manga only records the
+// time, and chapters published before it are never new, so that adding a
+// manga to a library does not flood it with old chapters.
+func checkManga(ctx context.Context, mangaID string, userIDs []string) (map[string][]store.Update, error) {
+	started := time.Now()
+	state, err := db.FeedState(mangaID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, db.SetFeedState(mangaID, store.FeedState{Since: started, Checked: started})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chapters, err := mangadex.GetChaptersSince(ctx, mangaID, state.Checked.Add(-feedOverlap))
+	if err != nil {
+		return nil, err
+	}
+	updates := make([]store.Update, 0, len(chapters))
+	for _, c := range chapters {
+		if c.Attributes.PublishAt.Before(state.Since) {
+			continue
+		}
+		updates = append(updates, store.Update{
+			MangaID:    mangaID,
+			MangaTitle: c.MangaTitle(),
+			ChapterID:  c.ID,
+			Chapter:    c.Heading(),
+			Language:   c.Attributes.TranslatedLanguage,
+			Published:  c.Attributes.PublishAt,
+		})
+	}
+
+	added, err := db.AddUpdates(userIDs, updates)
+	if err != nil {
+		return nil, err
+	}
+	metrics.NewChapters.Add(float64(countChapters(added)))
+	state.Checked = started
+	return added, db.SetFeedState(mangaID, *state)
+}
+
+// countChapters counts the distinct chapters given to any user.
+func countChapters(added map[string][]store.Update) int {
+	seen := make(map[string]bool)
+	for _, updates := range added {
+		for _, u := range updates {
+			seen[u.ChapterID] = true
+		}
+	}
+	return len(seen)
+}
+
+// unreadUpdateCount returns how many unread new chapters the visitor has.
+func unreadUpdateCount(r *http.Request) int {
+	user := currentUser(r)
+	if user == nil {
+		return 0
+	}
+	unread, err := db.UnreadUpdates(user.ID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error counting unread updates", "err", err)
+	}
+	total := 0
+	for _, n := range unread {
+		total += n
+	}
+	return total
+}
+
+// markUpdateRead marks the update for an opened chapter read.
+func markUpdateRead(r *http.Request, chapterID string) {
+	user := currentUser(r)
+	if user == nil {
+		return
+	}
+	if err := db.MarkUpdateRead(user.ID, chapterID); err != nil {
+		slog.WarnContext(r.Context(), "Error marking update read", "chapter_id", chapterID, "err", err)
+	}
+}
+
+// updatesHandler lists the new chapters of the manga in the user's
+// library, newest first, grouped by publish day.
+func updatesHandler(w http.ResponseWriter, r *http.Request) {
+	page := pageParam(r)
+	updates, total, err := db.Updates(currentUser(r).ID, (page-1)*updatesPageSize, updatesPageSize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading updates", "err", err)
+		http.Error(w, "Error reading updates", http.StatusInternalServerError)
+		return
+	}
+
+	var days []updateDay
+	for _, u := range updates {
+		label := dayLabel(u.Published.Local())
+		if len(days) == 0 || days[len(days)-1].Label != label {
+			days = append(days, updateDay{Label: label})
+		}
+		days[len(days)-1].Updates = append(days[len(days)-1].Updates, u)
+	}
+
+	data := struct {
+		Days       []updateDay
+		Pagination catalog.Pagination
+	}{
+		Days:       days,
+		Pagination: catalog.NewPagination(page, updatesPageSize, total),
+	}
+	render(w, r, "updates", data)
+}
+
+// markUpdatesReadHandler marks all of the user's updates read.
+func markUpdatesReadHandler(w http.ResponseWriter, r *http.Request) {
+	if err := db.MarkAllUpdatesRead(currentUser(r).ID); err != nil {
+		slog.ErrorContext(r.Context(), "Error marking updates read", "err", err)
+		http.Error(w, "Error marking updates read", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/updates", http.StatusSeeOther)
+}